@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Boyuan-Chen/v3-migration/config"
+	"github.com/Boyuan-Chen/v3-migration/engineapi"
+	"github.com/Boyuan-Chen/v3-migration/flags"
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/Boyuan-Chen/v3-migration/verify"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli"
+)
+
+var VerifyBodiesCommand = cli.Command{
+	Name:  "verify-bodies",
+	Usage: "Compare the transactions of migrated blocks with the legacy chain",
+	Description: "Fetches the blocks from --from-block to --to-block (or the Boba hard fork block) from the engine " +
+		"with engine_getPayloadBodiesByRangeV1, or eth_getBlockByNumber if it lacks that method, " +
+		"and compares their transactions with the legacy blocks without executing anything",
+	Flags:  []cli.Flag{flags.BatchSizeFlag, flags.WorkersFlag},
+	Action: verifyBodies,
+}
+
+func verifyBodies(ctx *cli.Context) error {
+	cfg, err := bodiesConfig(ctx)
+	if err != nil {
+		return err
+	}
+	from, to, err := blockRange(ctx)
+	if err != nil {
+		return err
+	}
+	batchSize, workers := ctx.Int(flags.BatchSizeFlag.Name), ctx.Int(flags.WorkersFlag.Name)
+	if batchSize <= 0 || workers <= 0 {
+		return fmt.Errorf("batch size and workers must be positive")
+	}
+	if batchSize > engineapi.MaxPayloadBodiesRange {
+		log.Warn("Batch size is larger than the engine serves, capping it", "batchSize", batchSize, "max", engineapi.MaxPayloadBodiesRange)
+		batchSize = engineapi.MaxPayloadBodiesRange
+	}
+
+	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	JWTSecret, err := cfg.GetJWTSecret()
+	if err != nil {
+		return err
+	}
+	l2LegacyRpc, err := rpc.NewLegacyRpcClient(rootCtx, cfg.L2LegacyEndpoint)
+	if err != nil {
+		return err
+	}
+	l2PrivateRpc, err := rpc.NewRpcClient(rootCtx, cfg.L2PrivateEndpoint, *JWTSecret)
+	if err != nil {
+		return err
+	}
+	l2EngineAPI, err := engineapi.NewEngineAPI(rootCtx, l2PrivateRpc, cfg)
+	if err != nil {
+		return err
+	}
+
+	mismatched, err := verify.SweepBodies(rootCtx, l2LegacyRpc, l2EngineAPI, from, to, uint64(batchSize), workers)
+	if err != nil {
+		return err
+	}
+	if mismatched > 0 {
+		return fmt.Errorf("%d of %d block bodies do not match the legacy chain", mismatched, to-from+1)
+	}
+	log.Info("Verified block bodies", "from", from, "to", to)
+	return nil
+}
+
+// bodiesConfig reads the settings verify-bodies needs. Unlike config.NewConfig
+// it doesn't require the Boba hard fork block, so --to-block alone is enough.
+func bodiesConfig(ctx *cli.Context) (*config.Config, error) {
+	if !ctx.GlobalIsSet(flags.L2LegacyEndpointFlag.Name) {
+		return nil, errors.New("L2 Legacy Endpoint is not set")
+	}
+	if !ctx.GlobalIsSet(flags.JWTSecretPathFlag.Name) {
+		return nil, errors.New("JWT Secret Path is not set")
+	}
+	cfg := &config.Config{
+		L2LegacyEndpoint:  ctx.GlobalString(flags.L2LegacyEndpointFlag.Name),
+		L2PrivateEndpoint: ctx.GlobalString(flags.L2PrivateEndpointFlag.Name),
+		JWTSecretPath:     ctx.GlobalString(flags.JWTSecretPathFlag.Name),
+		MaxWaitingTime:    ctx.GlobalInt(flags.MaxWaitingTimeFlag.Name),
+	}
+	cfg.ShanghaiTime, cfg.CancunTime = config.ForkTimes(ctx)
+	return cfg, nil
+}
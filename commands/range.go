@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Boyuan-Chen/v3-migration/flags"
+	"github.com/urfave/cli"
+)
+
+// blockRange returns the block range of a command, from --from-block to
+// --to-block or the Boba hard fork block
+func blockRange(ctx *cli.Context) (uint64, uint64, error) {
+	from := ctx.GlobalInt(flags.FromBlockFlag.Name)
+	var to int
+	switch {
+	case ctx.GlobalIsSet(flags.ToBlockFlag.Name):
+		to = ctx.GlobalInt(flags.ToBlockFlag.Name)
+	case ctx.GlobalIsSet(flags.BobaHardForkBlockFlag.Name):
+		to = ctx.GlobalInt(flags.BobaHardForkBlockFlag.Name)
+	default:
+		return 0, 0, errors.New("Neither the To Block nor the Boba Hard Fork Block is set")
+	}
+	if from < 0 || to < from {
+		return 0, 0, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	return uint64(from), uint64(to), nil
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Boyuan-Chen/v3-migration/flags"
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/Boyuan-Chen/v3-migration/turing"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli"
+)
+
+var DumpTuringCommand = cli.Command{
+	Name:  "dump-turing",
+	Usage: "Dump every legacy transaction carrying a Turing payload in a block range",
+	Description: "Scans the legacy blocks from --from-block to --to-block (or the Boba hard fork block) " +
+		"and writes one JSON record per Turing-bearing transaction",
+	Flags:  []cli.Flag{flags.OutputFlag},
+	Action: dumpTuring,
+}
+
+func dumpTuring(ctx *cli.Context) error {
+	if !ctx.GlobalIsSet(flags.L2LegacyEndpointFlag.Name) {
+		return errors.New("L2 Legacy Endpoint is not set")
+	}
+	from, to, err := blockRange(ctx)
+	if err != nil {
+		return err
+	}
+
+	rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	legacyRpc, err := rpc.NewLegacyRpcClient(rootCtx, ctx.GlobalString(flags.L2LegacyEndpointFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if path := ctx.String(flags.OutputFlag.Name); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("Failed to create output file: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	count, err := turing.Dump(rootCtx, legacyRpc, from, to, w)
+	if err != nil {
+		return err
+	}
+	log.Info("Dumped Turing transactions", "from", from, "to", to, "transactions", count)
+	return nil
+}
@@ -17,17 +17,78 @@ type Config struct {
 	L2PublicEndpoint  string
 	L2LegacyEndpoint  string
 	JWTSecretPath     string
+	JWTReloadInterval int
 	MaxWaitingTime    int
 	EpochLengthSecond int
 	BobaHardForkBlock int
+	PrefetchWindow    int
+	JournalPath       string
+	DryRun            bool
+	FromBlock         int
+	ToBlock           int
+	FailurePolicy     string
+	MaxRetries        int
+	RetryBackoff      int
+	DiagnosticDir     string
+	VerifyReceipts    bool
+	VerifyState       bool
+	VerifyMetadata    bool
+	VerifyTuring      bool
+
+	SafeDepth              int
+	FinalizedDepth         int
+	FinalizeInterval       int
+	FinalizeAtHardForkOnly bool
+	MineMode               string
+	PayloadStatusTimeout   int
+
+	// Fork times of the new chain, nil if the fork is not scheduled
+	ShanghaiTime *uint64
+	CancunTime   *uint64
+}
+
+// ForkTimes returns the Shanghai and Cancun fork times set on the command
+// line, nil when a fork isn't scheduled
+func ForkTimes(ctx *cli.Context) (shanghaiTime *uint64, cancunTime *uint64) {
+	if ctx.GlobalIsSet(flags.ShanghaiTimeFlag.Name) {
+		shanghai := ctx.GlobalUint64(flags.ShanghaiTimeFlag.Name)
+		shanghaiTime = &shanghai
+	}
+	if ctx.GlobalIsSet(flags.CancunTimeFlag.Name) {
+		cancun := ctx.GlobalUint64(flags.CancunTimeFlag.Name)
+		cancunTime = &cancun
+	}
+	return shanghaiTime, cancunTime
 }
 
 func NewConfig(ctx *cli.Context) *Config {
 	cfg := Config{}
 	cfg.L2PrivateEndpoint = ctx.GlobalString(flags.L2PrivateEndpointFlag.Name)
 	cfg.L2PublicEndpoint = ctx.GlobalString(flags.L2PublicEndpointFlag.Name)
+	cfg.JWTReloadInterval = ctx.GlobalInt(flags.JWTReloadIntervalFlag.Name)
 	cfg.MaxWaitingTime = ctx.GlobalInt(flags.MaxWaitingTimeFlag.Name)
 	cfg.EpochLengthSecond = ctx.GlobalInt(flags.EpochLengthSecondFlag.Name)
+	cfg.PrefetchWindow = ctx.GlobalInt(flags.PrefetchWindowFlag.Name)
+	cfg.JournalPath = ctx.GlobalString(flags.JournalPathFlag.Name)
+	cfg.DryRun = ctx.GlobalBool(flags.DryRunFlag.Name)
+	cfg.FromBlock = ctx.GlobalInt(flags.FromBlockFlag.Name)
+	cfg.ToBlock = ctx.GlobalInt(flags.ToBlockFlag.Name)
+	cfg.FailurePolicy = ctx.GlobalString(flags.FailurePolicyFlag.Name)
+	cfg.MaxRetries = ctx.GlobalInt(flags.MaxRetriesFlag.Name)
+	cfg.RetryBackoff = ctx.GlobalInt(flags.RetryBackoffSecondFlag.Name)
+	cfg.DiagnosticDir = ctx.GlobalString(flags.DiagnosticDirFlag.Name)
+	cfg.VerifyReceipts = ctx.GlobalBoolT(flags.VerifyReceiptsFlag.Name)
+	cfg.VerifyState = ctx.GlobalBool(flags.VerifyStateFlag.Name)
+	cfg.VerifyMetadata = ctx.GlobalBoolT(flags.VerifyMetadataFlag.Name)
+	cfg.VerifyTuring = ctx.GlobalBoolT(flags.VerifyTuringFlag.Name)
+	cfg.SafeDepth = ctx.GlobalInt(flags.SafeDepthFlag.Name)
+	cfg.FinalizedDepth = ctx.GlobalInt(flags.FinalizedDepthFlag.Name)
+	cfg.FinalizeInterval = ctx.GlobalInt(flags.FinalizeIntervalFlag.Name)
+	cfg.FinalizeAtHardForkOnly = ctx.GlobalBool(flags.FinalizeAtHardForkOnlyFlag.Name)
+	cfg.MineMode = ctx.GlobalString(flags.MineModeFlag.Name)
+	cfg.PayloadStatusTimeout = ctx.GlobalInt(flags.PayloadStatusTimeoutFlag.Name)
+
+	cfg.ShanghaiTime, cfg.CancunTime = ForkTimes(ctx)
 
 	if ctx.GlobalIsSet(flags.L2LegacyEndpointFlag.Name) {
 		cfg.L2LegacyEndpoint = ctx.GlobalString(flags.L2LegacyEndpointFlag.Name)
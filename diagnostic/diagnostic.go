@@ -0,0 +1,127 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Bundle is everything we know about a diverging block, from both chains.
+// It is written as a single JSON file so it can be shared as is.
+type Bundle struct {
+	BlockNumber  uint64                   `json:"blockNumber"`
+	Mismatch     string                   `json:"mismatch"`
+	CreatedAt    time.Time                `json:"createdAt"`
+	Legacy       *Chain                   `json:"legacy"`
+	Migrated     *Chain                   `json:"migrated"`
+	Transactions []*TransactionDiagnostic `json:"transactions"`
+}
+
+// Chain holds the block as returned by one of the endpoints
+type Chain struct {
+	Block  json.RawMessage `json:"block,omitempty"`
+	Errors []string        `json:"errors,omitempty"`
+}
+
+type TransactionDiagnostic struct {
+	Index    int                `json:"index"`
+	Hash     common.Hash        `json:"hash"`
+	Legacy   *TransactionResult `json:"legacy"`
+	Migrated *TransactionResult `json:"migrated"`
+}
+
+// TransactionResult is the outcome of a transaction on one chain
+type TransactionResult struct {
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Logs    json.RawMessage `json:"logs,omitempty"`
+	Receipt json.RawMessage `json:"receipt,omitempty"`
+	Trace   json.RawMessage `json:"trace,omitempty"`
+	Errors  []string        `json:"errors,omitempty"`
+}
+
+// Collect gathers the block, receipts and traces of a block from the legacy
+// and migrated chains. Failures are recorded in the bundle rather than
+// returned, so a partial bundle is still produced when an endpoint lacks a
+// method or the migrated block is not available.
+func Collect(ctx context.Context, l2LegacyRpc *rpc.RpcClient, l2PublicRpc *rpc.RpcClient, number uint64, txHashes []common.Hash, mismatch string) *Bundle {
+	bundle := &Bundle{
+		BlockNumber:  number,
+		Mismatch:     mismatch,
+		CreatedAt:    time.Now().UTC(),
+		Legacy:       collectBlock(ctx, l2LegacyRpc, number),
+		Migrated:     collectBlock(ctx, l2PublicRpc, number),
+		Transactions: make([]*TransactionDiagnostic, len(txHashes)),
+	}
+	for i, txHash := range txHashes {
+		bundle.Transactions[i] = &TransactionDiagnostic{
+			Index:    i,
+			Hash:     txHash,
+			Legacy:   collectTransaction(ctx, l2LegacyRpc, txHash),
+			Migrated: collectTransaction(ctx, l2PublicRpc, txHash),
+		}
+	}
+	return bundle
+}
+
+func collectBlock(ctx context.Context, rpcClient *rpc.RpcClient, number uint64) *Chain {
+	chain := &Chain{}
+	block, err := rpcClient.GetRawBlock(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		chain.Errors = append(chain.Errors, err.Error())
+	}
+	chain.Block = block
+	return chain
+}
+
+func collectTransaction(ctx context.Context, rpcClient *rpc.RpcClient, txHash common.Hash) *TransactionResult {
+	result := &TransactionResult{}
+
+	receipt, err := rpcClient.GetRawTransactionReceipt(ctx, txHash)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else if len(receipt) > 0 && string(receipt) != "null" {
+		result.Receipt = receipt
+		var fields struct {
+			GasUsed *hexutil.Uint64 `json:"gasUsed"`
+			Logs    json.RawMessage `json:"logs"`
+		}
+		if err := json.Unmarshal(receipt, &fields); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Failed to decode receipt: %v", err))
+		} else {
+			result.GasUsed = fields.GasUsed
+			result.Logs = fields.Logs
+		}
+	}
+
+	trace, err := rpcClient.TraceTransaction(ctx, txHash)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	result.Trace = trace
+
+	return result
+}
+
+// Write stores the bundle in dir and returns the path of the file
+func (b *Bundle) Write(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("Failed to create diagnostic directory: %v", err)
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode diagnostic bundle: %v", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("block-%d-%d.json", b.BlockNumber, b.CreatedAt.Unix()))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("Failed to write diagnostic bundle: %v", err)
+	}
+	return path, nil
+}
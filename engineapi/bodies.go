@@ -0,0 +1,94 @@
+package engineapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+const getPayloadBodiesByRangeV1 = "engine_getPayloadBodiesByRangeV1"
+
+// MaxPayloadBodiesRange is the largest count engine_getPayloadBodiesByRangeV1
+// has to serve; engines refuse larger requests
+const MaxPayloadBodiesRange = 1024
+
+// ExecutionPayloadBody is a block body as returned by
+// engine_getPayloadBodiesByRangeV1
+type ExecutionPayloadBody struct {
+	Transactions []Data      `json:"transactions"`
+	Withdrawals  Withdrawals `json:"withdrawals"`
+}
+
+// GetPayloadBodiesByRange returns the bodies of count blocks from start. A
+// nil body is a block the engine doesn't have. Engines without
+// engine_getPayloadBodiesByRangeV1 are asked with eth_getBlockByNumber
+// instead.
+func (e *EngineAPI) GetPayloadBodiesByRange(ctx context.Context, start uint64, count uint64) ([]*ExecutionPayloadBody, error) {
+	if e.supports(getPayloadBodiesByRangeV1) && !e.bodiesByRangeUnsupported.Load() {
+		bodies, err := e.getPayloadBodiesByRange(ctx, start, count)
+		if !errors.Is(err, ErrMethodNotFound) {
+			return bodies, err
+		}
+		if !e.bodiesByRangeUnsupported.Swap(true) {
+			log.Warn("Engine does not support engine_getPayloadBodiesByRangeV1, falling back to eth_getBlockByNumber")
+		}
+	}
+	return e.getBlockBodiesByRange(ctx, start, count)
+}
+
+func (e *EngineAPI) getPayloadBodiesByRange(ctx context.Context, start uint64, count uint64) ([]*ExecutionPayloadBody, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(e.Config.MaxWaitingTime))
+	defer cancel()
+	var bodies []*ExecutionPayloadBody
+	if err := e.Engine.CallContext(ctx, &bodies, getPayloadBodiesByRangeV1, hexutil.Uint64(start), hexutil.Uint64(count)); err != nil {
+		return nil, fmt.Errorf("Failed to obtain payload bodies: %w", newEngineError(getPayloadBodiesByRangeV1, err))
+	}
+	return bodies, nil
+}
+
+// getBlockBodiesByRange fetches the blocks in one batch call and encodes
+// their transactions the way engine_getPayloadBodiesByRangeV1 does
+func (e *EngineAPI) getBlockBodiesByRange(ctx context.Context, start uint64, count uint64) ([]*ExecutionPayloadBody, error) {
+	blocks := make([]*struct {
+		Transactions []*types.Transaction `json:"transactions"`
+	}, count)
+	batch := make([]gethrpc.BatchElem, count)
+	for i := range batch {
+		batch[i] = gethrpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []interface{}{hexutil.Uint64(start + uint64(i)), true},
+			Result: &blocks[i],
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(e.Config.MaxWaitingTime))
+	defer cancel()
+	if err := e.Engine.BatchCallContext(ctx, batch); err != nil {
+		return nil, fmt.Errorf("Failed to obtain blocks: %w", newEngineError("eth_getBlockByNumber", err))
+	}
+
+	bodies := make([]*ExecutionPayloadBody, count)
+	for i, elem := range batch {
+		if elem.Error != nil {
+			return nil, fmt.Errorf("Failed to obtain block %d: %w", start+uint64(i), newEngineError(elem.Method, elem.Error))
+		}
+		if blocks[i] == nil {
+			continue
+		}
+		body := &ExecutionPayloadBody{Transactions: make([]Data, len(blocks[i].Transactions))}
+		for j, tx := range blocks[i].Transactions {
+			data, err := tx.MarshalBinary()
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode transaction %d of block %d: %w", j, start+uint64(i), err)
+			}
+			body.Transactions[j] = data
+		}
+		bodies[i] = body
+	}
+	return bodies, nil
+}
@@ -0,0 +1,83 @@
+package engineapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var payloadMethods = []string{"engine_forkchoiceUpdated", "engine_getPayload", "engine_newPayload"}
+
+// clientCapabilities returns every engine method this client can call
+func clientCapabilities() []string {
+	var methods []string
+	for version := V1; version <= V3; version++ {
+		for _, method := range payloadMethods {
+			methods = append(methods, method+version.String())
+		}
+	}
+	return append(methods, getPayloadBodiesByRangeV1)
+}
+
+// exchangeCapabilities records the methods the engine supports and checks
+// that it has every method version the configured fork times require.
+// Engines that predate engine_exchangeCapabilities are assumed to support
+// everything, so unsupported methods only fail on first use.
+func (e *EngineAPI) exchangeCapabilities(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(e.Config.MaxWaitingTime))
+	defer cancel()
+	var result []string
+	if err := e.Engine.CallContext(ctx, &result, "engine_exchangeCapabilities", clientCapabilities()); err != nil {
+		engineErr := newEngineError("engine_exchangeCapabilities", err)
+		if errors.Is(engineErr, ErrMethodNotFound) {
+			log.Warn("Engine does not support engine_exchangeCapabilities, assuming it supports every method")
+			return nil
+		}
+		return fmt.Errorf("Failed to exchange capabilities: %w", engineErr)
+	}
+	e.capabilities = make(map[string]bool, len(result))
+	for _, method := range result {
+		e.capabilities[method] = true
+	}
+
+	var missing []string
+	for _, version := range e.requiredVersions() {
+		for _, method := range payloadMethods {
+			if !e.supports(method + version.String()) {
+				missing = append(missing, method+version.String())
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("engine does not support %s, required by the configured fork times", strings.Join(missing, ", "))
+	}
+	log.Info("Exchanged engine capabilities", "methods", len(result), "forkchoiceUpdated", e.forkchoiceVersion(nil))
+	return nil
+}
+
+// requiredVersions returns the method versions blocks may need under the
+// configured fork times
+func (e *EngineAPI) requiredVersions() []Version {
+	shanghai, cancun := e.Config.ShanghaiTime, e.Config.CancunTime
+	var versions []Version
+	if shanghai == nil || *shanghai > 0 {
+		versions = append(versions, V1)
+	}
+	if shanghai != nil && (cancun == nil || *cancun > *shanghai) {
+		versions = append(versions, V2)
+	}
+	if cancun != nil {
+		versions = append(versions, V3)
+	}
+	return versions
+}
+
+// supports reports whether the engine supports a method. Without a
+// capability exchange every method is assumed to be supported.
+func (e *EngineAPI) supports(method string) bool {
+	return e.capabilities == nil || e.capabilities[method]
+}
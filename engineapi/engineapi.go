@@ -3,62 +3,134 @@ package engineapi
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/Boyuan-Chen/v3-migration/config"
 	"github.com/Boyuan-Chen/v3-migration/rpc"
 	"github.com/ethereum-optimism/optimism/op-node/client"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/beacon"
 	"github.com/ethereum/go-ethereum/log"
 )
 
+// blobTxType is the EIP-4844 transaction type
+const blobTxType = 0x03
+
 type EngineAPI struct {
 	Engine client.RPC
 	Config *config.Config
+
+	// capabilities are the methods the engine reported at startup, nil if
+	// it doesn't support engine_exchangeCapabilities
+	capabilities map[string]bool
+	// bodiesByRangeUnsupported is set once the engine turned out not to
+	// have engine_getPayloadBodiesByRangeV1
+	bodiesByRangeUnsupported atomic.Bool
 }
 
-func NewEngineAPI(rpcClient *rpc.RpcClient, cfg *config.Config) (*EngineAPI, error) {
+// NewEngineAPI creates an engine client and exchanges capabilities with the
+// engine, failing if it lacks a method the configured fork times require
+func NewEngineAPI(ctx context.Context, rpcClient *rpc.RpcClient, cfg *config.Config) (*EngineAPI, error) {
 	if rpcClient == nil {
 		return nil, fmt.Errorf("Failed to create NewEngineAPI, rpcClient is nil")
 	}
-	return &EngineAPI{
+	e := &EngineAPI{
 		Engine: rpcClient.Client,
 		Config: cfg,
-	}, nil
+	}
+	if err := e.exchangeCapabilities(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
 }
 
-func (e *EngineAPI) ForkchoiceUpdate(fc *ForkchoiceState, attributes *PayloadAttributes) (*ForkchoiceUpdatedResult, error) {
-	// log.Info("ForkchoiceUpdate... (engine_forkchoiceUpdatedV1)")
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(e.Config.MaxWaitingTime))
-	defer cancel()
+// ForkchoiceUpdate updates the forkchoice, waiting while the engine is
+// syncing. A status other than VALID is returned as a PayloadStatusError.
+func (e *EngineAPI) ForkchoiceUpdate(ctx context.Context, fc *ForkchoiceState, attributes *PayloadAttributes) (*ForkchoiceUpdatedResult, error) {
+	method := fmt.Sprintf("engine_forkchoiceUpdated%s", e.forkchoiceVersion(attributes))
 	var result ForkchoiceUpdatedResult
-	if err := e.Engine.CallContext(ctx, &result, "engine_forkchoiceUpdatedV1", fc, attributes); err != nil {
-		return nil, fmt.Errorf("Failed to obtain new payloadId: %v", err)
+	err := e.awaitValid(ctx, method, func(ctx context.Context) (*PayloadStatusV1, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(e.Config.MaxWaitingTime))
+		defer cancel()
+		if err := e.Engine.CallContext(ctx, &result, method, fc, attributes); err != nil {
+			return nil, fmt.Errorf("Failed to obtain new payloadId: %w", newEngineError(method, err))
+		}
+		return &result.PayloadStatus, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	log.Info("ForkchoiceUpdate Success", "PayloadStatus", result.PayloadStatus.Status, "LatestValidHash", result.PayloadStatus.LatestValidHash)
+	log.Info("ForkchoiceUpdate Success", "method", method, "PayloadStatus", result.PayloadStatus.Status, "LatestValidHash", result.PayloadStatus.LatestValidHash)
 	return &result, nil
 }
 
-func (e *EngineAPI) GetPayload(payloadID *beacon.PayloadID) (*ExecutionPayload, error) {
-	// log.Info("GetPayload... (engine_getPayloadV1)")
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(e.Config.MaxWaitingTime))
+// GetPayload returns the payload built for the block with the given
+// timestamp, which selects the method version
+func (e *EngineAPI) GetPayload(ctx context.Context, payloadID *beacon.PayloadID, timestamp uint64) (*ExecutionPayloadEnvelope, error) {
+	version := e.PayloadVersion(timestamp)
+	method := fmt.Sprintf("engine_getPayload%s", version)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(e.Config.MaxWaitingTime))
 	defer cancel()
-	var result ExecutionPayload
-	if err := e.Engine.CallContext(ctx, &result, "engine_getPayloadV1", payloadID); err != nil {
-		return nil, fmt.Errorf("Failed to obtain new payloadId: %v", err)
+	var result ExecutionPayloadEnvelope
+	var err error
+	if version == V1 {
+		err = e.Engine.CallContext(ctx, &result.ExecutionPayload, method, payloadID)
+	} else {
+		err = e.Engine.CallContext(ctx, &result, method, payloadID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to obtain new payload: %w", newEngineError(method, err))
 	}
-	log.Info("GetPayload Success", "PayloadID", payloadID, "BlockHash", result.BlockHash)
+	if result.ExecutionPayload == nil {
+		return nil, fmt.Errorf("Failed to obtain new payload: %s returned no payload", method)
+	}
+	log.Info("GetPayload Success", "method", method, "PayloadID", payloadID, "BlockHash", result.ExecutionPayload.BlockHash, "BlockValue", result.BlockValue)
 	return &result, nil
 }
 
-func (e *EngineAPI) ExecutePayload(executionPayload *ExecutionPayload) (*PayloadStatusV1, error) {
-	// log.Info("ExecutePayload... (engine_newPayloadV1)")
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(e.Config.MaxWaitingTime))
-	defer cancel()
+// ExecutePayload executes a payload, waiting while the engine is syncing. A
+// status other than VALID is returned as a PayloadStatusError. From V3 on,
+// the parent beacon block root the payload was built with must be passed
+// along.
+func (e *EngineAPI) ExecutePayload(ctx context.Context, executionPayload *ExecutionPayload, parentBeaconBlockRoot *common.Hash) (*PayloadStatusV1, error) {
+	version := e.PayloadVersion(uint64(executionPayload.Timestamp))
+	method := fmt.Sprintf("engine_newPayload%s", version)
+	params := []interface{}{executionPayload}
+	if version >= V3 {
+		if parentBeaconBlockRoot == nil {
+			return nil, fmt.Errorf("Failed to execute new payload: %s requires a parent beacon block root", method)
+		}
+		versionedHashes, err := blobVersionedHashes(executionPayload)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to execute new payload: %v", err)
+		}
+		params = append(params, versionedHashes, parentBeaconBlockRoot)
+	}
 	var result PayloadStatusV1
-	if err := e.Engine.CallContext(ctx, &result, "engine_newPayloadV1", executionPayload); err != nil {
-		return nil, fmt.Errorf("Failed to execute new payloadId: %v", err)
+	err := e.awaitValid(ctx, method, func(ctx context.Context) (*PayloadStatusV1, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(e.Config.MaxWaitingTime))
+		defer cancel()
+		if err := e.Engine.CallContext(ctx, &result, method, params...); err != nil {
+			return nil, fmt.Errorf("Failed to execute new payload: %w", newEngineError(method, err))
+		}
+		return &result, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	log.Info("ExecutePayload Result", "PayloadStatus", result.Status, "LatestValidHash", result.LatestValidHash)
+	log.Info("ExecutePayload Result", "method", method, "PayloadStatus", result.Status, "LatestValidHash", result.LatestValidHash)
 	return &result, nil
 }
+
+// blobVersionedHashes returns the versioned hashes of the blobs referenced by
+// a payload. Legacy blocks can't contain blob transactions, so the list is
+// always empty and a blob transaction is rejected rather than decoded.
+func blobVersionedHashes(executionPayload *ExecutionPayload) ([]common.Hash, error) {
+	for i, tx := range executionPayload.Transactions {
+		if len(tx) > 0 && tx[0] == blobTxType {
+			return nil, fmt.Errorf("transaction %d is a blob transaction", i)
+		}
+	}
+	return []common.Hash{}, nil
+}
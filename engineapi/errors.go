@@ -0,0 +1,107 @@
+package engineapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// JSON-RPC error codes returned by engines
+const (
+	CodeParseError               = -32700
+	CodeInvalidRequest           = -32600
+	CodeMethodNotFound           = -32601
+	CodeInvalidParams            = -32602
+	CodeInternalError            = -32603
+	CodeServerError              = -32000
+	CodeUnknownPayload           = -38001
+	CodeInvalidForkchoiceState   = -38002
+	CodeInvalidPayloadAttributes = -38003
+	CodeTooLargeRequest          = -38004
+	CodeUnsupportedFork          = -38005
+)
+
+var (
+	ErrParse                    = errors.New("parse error")
+	ErrInvalidRequest           = errors.New("invalid request")
+	ErrMethodNotFound           = errors.New("method not found")
+	ErrInvalidParams            = errors.New("invalid params")
+	ErrInternal                 = errors.New("internal error")
+	ErrServer                   = errors.New("server error")
+	ErrUnknownPayload           = errors.New("unknown payload")
+	ErrInvalidForkchoiceState   = errors.New("invalid forkchoice state")
+	ErrInvalidPayloadAttributes = errors.New("invalid payload attributes")
+	ErrTooLargeRequest          = errors.New("too large request")
+	ErrUnsupportedFork          = errors.New("unsupported fork")
+	// ErrUnauthorized matches a call the engine refused because of the JWT
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+var codeErrors = map[int]error{
+	CodeParseError:               ErrParse,
+	CodeInvalidRequest:           ErrInvalidRequest,
+	CodeMethodNotFound:           ErrMethodNotFound,
+	CodeInvalidParams:            ErrInvalidParams,
+	CodeInternalError:            ErrInternal,
+	CodeServerError:              ErrServer,
+	CodeUnknownPayload:           ErrUnknownPayload,
+	CodeInvalidForkchoiceState:   ErrInvalidForkchoiceState,
+	CodeInvalidPayloadAttributes: ErrInvalidPayloadAttributes,
+	CodeTooLargeRequest:          ErrTooLargeRequest,
+	CodeUnsupportedFork:          ErrUnsupportedFork,
+}
+
+// EngineError is a failed engine call. It matches the sentinel error of its
+// JSON-RPC code with errors.Is, or ErrUnauthorized if the engine refused
+// the JWT. Transport failures have no code and match neither.
+type EngineError struct {
+	Method  string
+	Code    int
+	Message string
+	// StatusCode is the HTTP status of the response, 0 if there was none
+	StatusCode int
+	err        error
+}
+
+// newEngineError decodes the error of a call to an engine method
+func newEngineError(method string, err error) *EngineError {
+	engineErr := &EngineError{
+		Method:  method,
+		Message: err.Error(),
+		err:     err,
+	}
+	var rpcErr gethrpc.Error
+	if errors.As(err, &rpcErr) {
+		engineErr.Code = rpcErr.ErrorCode()
+	}
+	var httpErr gethrpc.HTTPError
+	if errors.As(err, &httpErr) {
+		engineErr.StatusCode = httpErr.StatusCode
+	}
+	return engineErr
+}
+
+func (e *EngineError) Is(target error) bool {
+	if target == ErrUnauthorized {
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	codeErr, ok := codeErrors[e.Code]
+	return ok && codeErr == target
+}
+
+func (e *EngineError) Unwrap() error {
+	return e.err
+}
+
+func (e *EngineError) Error() string {
+	switch {
+	case e.Code != 0:
+		return fmt.Sprintf("%s failed with code %d: %s", e.Method, e.Code, e.Message)
+	case e.StatusCode != 0:
+		return fmt.Sprintf("%s failed with HTTP status %d: %s", e.Method, e.StatusCode, e.Message)
+	default:
+		return fmt.Sprintf("%s failed: %s", e.Method, e.Message)
+	}
+}
@@ -0,0 +1,119 @@
+package engineapi
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// ErrUnsupportedHeader is returned for payloads whose header has fields this
+// client can't hash, such as the withdrawals root
+var ErrUnsupportedHeader = errors.New("header can't be recomputed")
+
+// Seal holds the header fields an execution payload doesn't carry. Engines
+// rebuilding legacy blocks keep the legacy values, while post-merge blocks
+// use the zero seal.
+type Seal struct {
+	UncleHash  common.Hash
+	Difficulty *big.Int
+	Nonce      types.BlockNonce
+}
+
+// BlockHashError is returned when the block hash reported in a payload is not
+// the hash of its header
+type BlockHashError struct {
+	Number   uint64
+	Reported common.Hash
+	Computed common.Hash
+}
+
+func (e *BlockHashError) Error() string {
+	return fmt.Sprintf("payload %d reports block hash %s, header hashes to %s", e.Number, e.Reported, e.Computed)
+}
+
+// PayloadHeader rebuilds the header of a payload, recomputing the
+// transactions root from the decoded transactions. A nil seal is the
+// post-merge seal.
+func PayloadHeader(payload *ExecutionPayload, seal *Seal) (*types.Header, types.Transactions, error) {
+	if payload.Withdrawals != nil || payload.BlobGasUsed != nil || payload.ExcessBlobGas != nil {
+		return nil, nil, fmt.Errorf("payload %d has V2 or V3 fields: %w", uint64(payload.BlockNumber), ErrUnsupportedHeader)
+	}
+	if len(payload.LogsBloom) != types.BloomByteLength {
+		return nil, nil, fmt.Errorf("payload %d has a logs bloom of %d bytes", uint64(payload.BlockNumber), len(payload.LogsBloom))
+	}
+	txs := make(types.Transactions, len(payload.Transactions))
+	for i, data := range payload.Transactions {
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(data); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode transaction %d of payload %d: %w", i, uint64(payload.BlockNumber), err)
+		}
+		txs[i] = &tx
+	}
+	if seal == nil {
+		seal = &Seal{UncleHash: types.EmptyUncleHash, Difficulty: common.Big0}
+	}
+	header := &types.Header{
+		ParentHash:  payload.ParentHash,
+		UncleHash:   seal.UncleHash,
+		Coinbase:    payload.FeeRecipient,
+		Root:        payload.StateRoot,
+		TxHash:      types.DeriveSha(txs, trie.NewStackTrie(nil)),
+		ReceiptHash: payload.ReceiptsRoot,
+		Bloom:       types.BytesToBloom(payload.LogsBloom),
+		Difficulty:  seal.Difficulty,
+		Number:      new(big.Int).SetUint64(uint64(payload.BlockNumber)),
+		GasLimit:    uint64(payload.GasLimit),
+		GasUsed:     uint64(payload.GasUsed),
+		Time:        uint64(payload.Timestamp),
+		Extra:       payload.ExtraData,
+		MixDigest:   payload.PrevRandao,
+		Nonce:       seal.Nonce,
+	}
+	if payload.BaseFeePerGas != nil {
+		header.BaseFee = payload.BaseFeePerGas.ToInt()
+	}
+	return header, txs, nil
+}
+
+// VerifyBlockHash rebuilds the header of a payload and checks that it hashes
+// to the reported block hash
+func VerifyBlockHash(payload *ExecutionPayload, seal *Seal) (*types.Header, error) {
+	header, _, err := PayloadHeader(payload, seal)
+	if err != nil {
+		return nil, err
+	}
+	if hash := header.Hash(); hash != payload.BlockHash {
+		return header, &BlockHashError{
+			Number:   uint64(payload.BlockNumber),
+			Reported: payload.BlockHash,
+			Computed: hash,
+		}
+	}
+	return header, nil
+}
+
+// PayloadFromLegacyBlock builds the execution payload of a legacy block from
+// its header and encoded transactions
+func PayloadFromLegacyBlock(block *rpc.LegacyBlock, transactions []Data) *ExecutionPayload {
+	return &ExecutionPayload{
+		ParentHash:    block.ParentHash,
+		FeeRecipient:  block.Coinbase,
+		StateRoot:     block.Root,
+		ReceiptsRoot:  block.ReceiptHash,
+		LogsBloom:     block.Bloom,
+		PrevRandao:    block.MixDigest,
+		BlockNumber:   block.Number,
+		GasLimit:      block.GasLimit,
+		GasUsed:       block.GasUsed,
+		Timestamp:     block.Time,
+		ExtraData:     block.Extra,
+		BaseFeePerGas: block.BaseFee,
+		BlockHash:     block.Hash,
+		Transactions:  transactions,
+	}
+}
@@ -0,0 +1,130 @@
+package engineapi
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// knownHash is the hash of the header built by knownBlock
+var knownHash = common.HexToHash("0xc0069a6e4ec9f500f066ed23475b110b5d4285fc131cd7c77a460205b881d0ca")
+
+// knownBlock builds a legacy style block with a transaction, a non-empty seal
+// and a base fee. geth assembles it independently of PayloadHeader, deriving
+// the transactions root itself.
+func knownBlock(t *testing.T) (*types.Block, *Seal) {
+	t.Helper()
+	tx := types.NewTransaction(3, common.HexToAddress("0x4200000000000000000000000000000000000016"), big.NewInt(1), 21000, big.NewInt(1000000000), nil)
+	seal := &Seal{
+		UncleHash:  types.EmptyUncleHash,
+		Difficulty: big.NewInt(2),
+		Nonce:      types.EncodeNonce(0),
+	}
+	header := &types.Header{
+		ParentHash:  common.HexToHash("0x1fa1a4e4c1c4f3e1b4d5b7e0f94e5e4b0f0d0d5e3a2b1c0d9e8f7a6b5c4d3e2f"),
+		UncleHash:   seal.UncleHash,
+		Coinbase:    common.HexToAddress("0x4200000000000000000000000000000000000011"),
+		Root:        common.HexToHash("0x2c0a1f5b3e4d6c7b8a9f0e1d2c3b4a5968778695a4b3c2d1e0f9a8b7c6d5e4f3"),
+		ReceiptHash: common.HexToHash("0x3d1b2a6c4f5e7d8c9b0a1f2e3d4c5b6a7988a7b6c5d4e3f2a1b0c9d8e7f6a5b4"),
+		Bloom:       types.BytesToBloom([]byte{0x01}),
+		Difficulty:  seal.Difficulty,
+		Number:      big.NewInt(1000),
+		GasLimit:    11000000,
+		GasUsed:     21000,
+		Time:        1650000000,
+		Extra:       []byte("legacy"),
+		Nonce:       seal.Nonce,
+		BaseFee:     big.NewInt(7),
+	}
+	return types.NewBlock(header, types.Transactions{tx}, nil, nil, trie.NewStackTrie(nil)), seal
+}
+
+func payloadOf(t *testing.T, block *types.Block) *ExecutionPayload {
+	t.Helper()
+	transactions := make([]Data, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		transactions[i] = data
+	}
+	return &ExecutionPayload{
+		ParentHash:    block.ParentHash(),
+		FeeRecipient:  block.Coinbase(),
+		StateRoot:     block.Root(),
+		ReceiptsRoot:  block.ReceiptHash(),
+		LogsBloom:     block.Bloom().Bytes(),
+		PrevRandao:    block.MixDigest(),
+		BlockNumber:   hexutil.Uint64(block.NumberU64()),
+		GasLimit:      hexutil.Uint64(block.GasLimit()),
+		GasUsed:       hexutil.Uint64(block.GasUsed()),
+		Timestamp:     hexutil.Uint64(block.Time()),
+		ExtraData:     block.Extra(),
+		BaseFeePerGas: (*hexutil.Big)(block.BaseFee()),
+		BlockHash:     block.Hash(),
+		Transactions:  transactions,
+	}
+}
+
+func TestPayloadHeaderRebuildsKnownHeader(t *testing.T) {
+	block, seal := knownBlock(t)
+	header, txs, err := PayloadHeader(payloadOf(t, block), seal)
+	if err != nil {
+		t.Fatalf("PayloadHeader: %v", err)
+	}
+	if len(txs) != 1 || txs[0].Hash() != block.Transactions()[0].Hash() {
+		t.Fatalf("PayloadHeader decoded %d transactions, want the block's one", len(txs))
+	}
+	if header.TxHash != block.TxHash() {
+		t.Fatalf("transactions root = %s, want %s", header.TxHash, block.TxHash())
+	}
+	if header.Hash() != knownHash || block.Hash() != knownHash {
+		t.Fatalf("header hash = %s, block hash = %s, want %s", header.Hash(), block.Hash(), knownHash)
+	}
+}
+
+func TestVerifyBlockHash(t *testing.T) {
+	block, seal := knownBlock(t)
+
+	if _, err := VerifyBlockHash(payloadOf(t, block), seal); err != nil {
+		t.Fatalf("VerifyBlockHash of a matching payload: %v", err)
+	}
+
+	reported := payloadOf(t, block)
+	reported.BlockHash = common.HexToHash("0x01")
+	_, err := VerifyBlockHash(reported, seal)
+	var hashErr *BlockHashError
+	if !errors.As(err, &hashErr) {
+		t.Fatalf("VerifyBlockHash with a wrong reported hash = %v, want a BlockHashError", err)
+	}
+	if hashErr.Computed != block.Hash() || hashErr.Reported != reported.BlockHash {
+		t.Fatalf("BlockHashError = %+v, want computed %s", hashErr, block.Hash())
+	}
+
+	// The legacy seal is part of the hash, so the post-merge seal must not
+	// reproduce it
+	if _, err := VerifyBlockHash(payloadOf(t, block), nil); !errors.As(err, &hashErr) {
+		t.Fatalf("VerifyBlockHash with the post-merge seal = %v, want a BlockHashError", err)
+	}
+
+	tampered := payloadOf(t, block)
+	tampered.GasUsed++
+	if _, err := VerifyBlockHash(tampered, seal); !errors.As(err, &hashErr) {
+		t.Fatalf("VerifyBlockHash of a tampered payload = %v, want a BlockHashError", err)
+	}
+}
+
+func TestPayloadHeaderRejectsForkFields(t *testing.T) {
+	block, seal := knownBlock(t)
+	payload := payloadOf(t, block)
+	payload.Withdrawals = &Withdrawals{}
+	if _, _, err := PayloadHeader(payload, seal); !errors.Is(err, ErrUnsupportedHeader) {
+		t.Fatalf("PayloadHeader with withdrawals = %v, want ErrUnsupportedHeader", err)
+	}
+}
@@ -0,0 +1,110 @@
+package engineapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	statusPollInterval    = 100 * time.Millisecond
+	maxStatusPollInterval = 5 * time.Second
+)
+
+var (
+	// ErrPayloadInvalid matches a payload the engine rejected
+	ErrPayloadInvalid = errors.New("payload is invalid")
+	// ErrPayloadNotValidated matches a payload the engine was still syncing
+	// or had only accepted when the deadline passed
+	ErrPayloadNotValidated = errors.New("payload was not validated in time")
+)
+
+// PayloadStatusError is returned when the engine reports a payload status
+// other than VALID
+type PayloadStatusError struct {
+	Method          string
+	Status          ExecutePayloadStatus
+	LatestValidHash *common.Hash
+	ValidationError string
+}
+
+func newPayloadStatusError(method string, status *PayloadStatusV1) *PayloadStatusError {
+	err := &PayloadStatusError{
+		Method:          method,
+		Status:          status.Status,
+		LatestValidHash: status.LatestValidHash,
+	}
+	if status.ValidationError != nil {
+		err.ValidationError = *status.ValidationError
+	}
+	return err
+}
+
+// IsInvalid reports whether the engine rejected the payload, as opposed to
+// not having validated it yet
+func (e *PayloadStatusError) IsInvalid() bool {
+	switch e.Status {
+	case ExecutionInvalid, ExecutionInvalidBlockHash, ExecutionInvalidTerminalBlock:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *PayloadStatusError) Is(target error) bool {
+	switch target {
+	case ErrPayloadInvalid:
+		return e.IsInvalid()
+	case ErrPayloadNotValidated:
+		return e.Status == ExecutionSyncing || e.Status == ExecutionAccepted
+	default:
+		return false
+	}
+}
+
+func (e *PayloadStatusError) Error() string {
+	if e.ValidationError != "" {
+		return fmt.Sprintf("%s returned status %s: %s", e.Method, e.Status, e.ValidationError)
+	}
+	return fmt.Sprintf("%s returned status %s", e.Method, e.Status)
+}
+
+// awaitValid repeats call while the engine reports SYNCING or ACCEPTED, with
+// an exponential backoff, until it returns another status or the configured
+// deadline passes. Any status other than VALID is returned as a
+// PayloadStatusError.
+func (e *EngineAPI) awaitValid(ctx context.Context, method string, call func(ctx context.Context) (*PayloadStatusV1, error)) error {
+	deadline := time.Now().Add(time.Second * time.Duration(e.Config.PayloadStatusTimeout))
+	backoff := statusPollInterval
+	for {
+		status, err := call(ctx)
+		if err != nil {
+			return err
+		}
+		switch status.Status {
+		case ExecutionValid:
+			return nil
+		case ExecutionSyncing, ExecutionAccepted:
+			if time.Now().Add(backoff).After(deadline) {
+				return newPayloadStatusError(method, status)
+			}
+			log.Info("Engine has not validated payload yet, polling", "method", method, "status", status.Status, "backoff", backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if backoff *= 2; backoff > maxStatusPollInterval {
+				backoff = maxStatusPollInterval
+			}
+		default:
+			statusErr := newPayloadStatusError(method, status)
+			log.Warn("Engine rejected payload", "method", method, "status", status.Status, "validationError", statusErr.ValidationError, "latestValidHash", status.LatestValidHash)
+			return statusErr
+		}
+	}
+}
@@ -27,8 +27,37 @@ type ExecutionPayload struct {
 	// Array of transaction objects, each object is a byte list (DATA) representing
 	// TransactionType || TransactionPayload or LegacyTransaction as defined in EIP-2718
 	Transactions []Data `json:"transactions"`
+	// Withdrawals were added in V2 (Shanghai) and must be left out before it
+	Withdrawals *Withdrawals `json:"withdrawals,omitempty"`
+	// BlobGasUsed and ExcessBlobGas were added in V3 (Cancun)
+	BlobGasUsed   *hexutil.Uint64 `json:"blobGasUsed,omitempty"`
+	ExcessBlobGas *hexutil.Uint64 `json:"excessBlobGas,omitempty"`
 }
 
+// ExecutionPayloadEnvelope is the result of engine_getPayloadV2 and V3. V1
+// only returns the payload, so its block value is nil.
+type ExecutionPayloadEnvelope struct {
+	ExecutionPayload      *ExecutionPayload `json:"executionPayload"`
+	BlockValue            *hexutil.Big      `json:"blockValue"`
+	BlobsBundle           *BlobsBundle      `json:"blobsBundle,omitempty"`
+	ShouldOverrideBuilder bool              `json:"shouldOverrideBuilder,omitempty"`
+}
+
+type BlobsBundle struct {
+	Commitments []hexutil.Bytes `json:"commitments"`
+	Proofs      []hexutil.Bytes `json:"proofs"`
+	Blobs       []hexutil.Bytes `json:"blobs"`
+}
+
+type Withdrawal struct {
+	Index     hexutil.Uint64 `json:"index"`
+	Validator hexutil.Uint64 `json:"validatorIndex"`
+	Address   common.Address `json:"address"`
+	Amount    hexutil.Uint64 `json:"amount"`
+}
+
+type Withdrawals []*Withdrawal
+
 type PayloadAttributes struct {
 	Timestamp             hexutil.Uint64  `json:"timestamp"`
 	PrevRandao            common.Hash     `json:"prevRandao"`
@@ -36,6 +65,8 @@ type PayloadAttributes struct {
 	Transactions          []hexutil.Bytes `json:"transactions"`
 	NoTxPool              bool            `json:"noTxPool"`
 	GasLimit              *hexutil.Uint64 `json:"gasLimit,omitempty"`
+	Withdrawals           *Withdrawals    `json:"withdrawals,omitempty"`
+	ParentBeaconBlockRoot *common.Hash    `json:"parentBeaconBlockRoot,omitempty"`
 }
 
 type ExecutePayloadStatus string
@@ -0,0 +1,52 @@
+package engineapi
+
+import "fmt"
+
+// Version is the version of the engine API methods used for a block
+type Version int
+
+const (
+	V1 Version = iota + 1
+	// V2 adds withdrawals (Shanghai)
+	V2
+	// V3 adds blob gas and the parent beacon block root (Cancun)
+	V3
+)
+
+func (v Version) String() string {
+	return fmt.Sprintf("V%d", int(v))
+}
+
+// PayloadVersion returns the engine API version for a block with the given
+// timestamp, following the fork times in the config
+func (e *EngineAPI) PayloadVersion(timestamp uint64) Version {
+	if e.Config.CancunTime != nil && timestamp >= *e.Config.CancunTime {
+		return V3
+	}
+	if e.Config.ShanghaiTime != nil && timestamp >= *e.Config.ShanghaiTime {
+		return V2
+	}
+	return V1
+}
+
+// forkchoiceVersion returns the version of engine_forkchoiceUpdated. Without
+// attributes there is no block timestamp, but every version accepts a plain
+// forkchoice update, so the newest configured version the engine supports
+// is used.
+func (e *EngineAPI) forkchoiceVersion(attributes *PayloadAttributes) Version {
+	if attributes != nil {
+		return e.PayloadVersion(uint64(attributes.Timestamp))
+	}
+	newest := V1
+	if e.Config.CancunTime != nil {
+		newest = V3
+	} else if e.Config.ShanghaiTime != nil {
+		newest = V2
+	}
+	for version := newest; version > V1; version-- {
+		if e.supports("engine_forkchoiceUpdated" + version.String()) {
+			return version
+		}
+	}
+	return V1
+}
@@ -27,6 +27,12 @@ var (
 		Usage:  "Path to JWT secret",
 		EnvVar: "JWT_SECRET_PATH",
 	}
+	JWTReloadIntervalFlag = cli.IntFlag{
+		Name:   "jwt-reload-interval",
+		Value:  10,
+		Usage:  "How often to check the JWT secret file for changes, 0 only reloads it on SIGHUP (second)",
+		EnvVar: "JWT_RELOAD_INTERVAL",
+	}
 	MaxWaitingTimeFlag = cli.IntFlag{
 		Name:   "max-waiting-time",
 		Value:  5,
@@ -44,6 +50,137 @@ var (
 		Usage:  "Boba hard fork block number",
 		EnvVar: "BOBA_HARD_FORK_BLOCK",
 	}
+	PrefetchWindowFlag = cli.IntFlag{
+		Name:   "prefetch-window",
+		Value:  16,
+		Usage:  "Number of legacy blocks fetched ahead of the engine",
+		EnvVar: "PREFETCH_WINDOW",
+	}
+	JournalPathFlag = cli.StringFlag{
+		Name:   "journal-path",
+		Value:  "migration-journal.jsonl",
+		Usage:  "Path to the checkpoint journal",
+		EnvVar: "JOURNAL_PATH",
+	}
+	DryRunFlag = cli.BoolFlag{
+		Name:   "dry-run",
+		Usage:  "Build the next block and compare it to the legacy block without committing it",
+		EnvVar: "DRY_RUN",
+	}
+	FromBlockFlag = cli.IntFlag{
+		Name:   "from-block",
+		Usage:  "First block to migrate, the engine head must be the block before it",
+		EnvVar: "FROM_BLOCK",
+	}
+	ToBlockFlag = cli.IntFlag{
+		Name:   "to-block",
+		Usage:  "Last block to migrate, defaults to the Boba hard fork block",
+		EnvVar: "TO_BLOCK",
+	}
+	FailurePolicyFlag = cli.StringFlag{
+		Name:   "failure-policy",
+		Value:  "retry",
+		Usage:  "What to do when a migrated block does not match the legacy block: retry, rollback or halt",
+		EnvVar: "FAILURE_POLICY",
+	}
+	MaxRetriesFlag = cli.IntFlag{
+		Name:   "max-retries",
+		Value:  3,
+		Usage:  "Maximum number of retries of a mismatched block before halting",
+		EnvVar: "MAX_RETRIES",
+	}
+	RetryBackoffSecondFlag = cli.IntFlag{
+		Name:   "retry-backoff-second",
+		Value:  1,
+		Usage:  "Initial backoff before retrying a mismatched block, doubled on every retry (second)",
+		EnvVar: "RETRY_BACKOFF_SECOND",
+	}
+	DiagnosticDirFlag = cli.StringFlag{
+		Name:   "diagnostic-dir",
+		Value:  "diagnostics",
+		Usage:  "Directory for diagnostic bundles written on state or receipts root mismatch",
+		EnvVar: "DIAGNOSTIC_DIR",
+	}
+	VerifyReceiptsFlag = cli.BoolTFlag{
+		Name:   "verify-receipts",
+		Usage:  "Compare the receipt of every migrated transaction with the legacy receipt",
+		EnvVar: "VERIFY_RECEIPTS",
+	}
+	VerifyStateFlag = cli.BoolFlag{
+		Name:   "verify-state",
+		Usage:  "Compare the state of every account and storage slot touched by a migrated block with the legacy chain (requires the debug namespace on the legacy endpoint)",
+		EnvVar: "VERIFY_STATE",
+	}
+	VerifyMetadataFlag = cli.BoolTFlag{
+		Name:   "verify-metadata",
+		Usage:  "Compare the legacy metadata of every migrated transaction with the legacy chain",
+		EnvVar: "VERIFY_METADATA",
+	}
+	VerifyTuringFlag = cli.BoolTFlag{
+		Name:   "verify-turing",
+		Usage:  "Compare the Turing payload of every migrated transaction that carries one with the legacy chain",
+		EnvVar: "VERIFY_TURING",
+	}
+	SafeDepthFlag = cli.IntFlag{
+		Name:   "safe-depth",
+		Usage:  "Number of blocks the safe block trails the head",
+		EnvVar: "SAFE_DEPTH",
+	}
+	FinalizedDepthFlag = cli.IntFlag{
+		Name:   "finalized-depth",
+		Usage:  "Number of blocks the finalized block trails the head. Must be at least 1 for the retry and rollback failure policies, which rewind bad blocks",
+		Value:  1,
+		EnvVar: "FINALIZED_DEPTH",
+	}
+	FinalizeIntervalFlag = cli.IntFlag{
+		Name:   "finalize-interval",
+		Usage:  "Only finalize blocks whose number is a multiple of this interval (0 finalizes every block)",
+		EnvVar: "FINALIZE_INTERVAL",
+	}
+	FinalizeAtHardForkOnlyFlag = cli.BoolFlag{
+		Name:   "finalize-at-hard-fork-only",
+		Usage:  "Leave the finalized block unset until the hard fork block is reached",
+		EnvVar: "FINALIZE_AT_HARD_FORK_ONLY",
+	}
+	MineModeFlag = cli.StringFlag{
+		Name:   "mine-mode",
+		Value:  "build",
+		Usage:  "How blocks are submitted: build has the engine build and compares them, direct executes the legacy block as is (only for blocks before Shanghai)",
+		EnvVar: "MINE_MODE",
+	}
+	PayloadStatusTimeoutFlag = cli.IntFlag{
+		Name:   "payload-status-timeout",
+		Value:  60,
+		Usage:  "How long to poll the engine while it reports a payload as SYNCING or ACCEPTED (second)",
+		EnvVar: "PAYLOAD_STATUS_TIMEOUT",
+	}
+	ShanghaiTimeFlag = cli.Uint64Flag{
+		Name:   "shanghai-time",
+		Usage:  "Timestamp of the Shanghai fork on the new chain, from which the V2 engine API is used",
+		EnvVar: "SHANGHAI_TIME",
+	}
+	CancunTimeFlag = cli.Uint64Flag{
+		Name:   "cancun-time",
+		Usage:  "Timestamp of the Cancun fork on the new chain, from which the V3 engine API is used",
+		EnvVar: "CANCUN_TIME",
+	}
+)
+
+var (
+	OutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "File to write to, defaults to stdout",
+	}
+	BatchSizeFlag = cli.IntFlag{
+		Name:  "batch-size",
+		Value: 64,
+		Usage: "Number of blocks fetched per call, at most 1024",
+	}
+	WorkersFlag = cli.IntFlag{
+		Name:  "workers",
+		Value: 4,
+		Usage: "Number of batches fetched in parallel",
+	}
 )
 
 var Flags = []cli.Flag{
@@ -51,7 +188,29 @@ var Flags = []cli.Flag{
 	L2PublicEndpointFlag,
 	L2LegacyEndpointFlag,
 	JWTSecretPathFlag,
+	JWTReloadIntervalFlag,
 	MaxWaitingTimeFlag,
 	EpochLengthSecondFlag,
 	BobaHardForkBlockFlag,
+	PrefetchWindowFlag,
+	JournalPathFlag,
+	DryRunFlag,
+	FromBlockFlag,
+	ToBlockFlag,
+	FailurePolicyFlag,
+	MaxRetriesFlag,
+	RetryBackoffSecondFlag,
+	DiagnosticDirFlag,
+	VerifyReceiptsFlag,
+	VerifyStateFlag,
+	VerifyMetadataFlag,
+	VerifyTuringFlag,
+	SafeDepthFlag,
+	FinalizedDepthFlag,
+	FinalizeIntervalFlag,
+	FinalizeAtHardForkOnlyFlag,
+	MineModeFlag,
+	PayloadStatusTimeoutFlag,
+	ShanghaiTimeFlag,
+	CancunTimeFlag,
 }
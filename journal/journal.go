@@ -0,0 +1,142 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Entry is a checkpoint written after a migrated block has become the engine
+// head, or a record of how a failed block was handled
+type Entry struct {
+	Number     uint64      `json:"number"`
+	LegacyHash common.Hash `json:"legacyHash"`
+	NewHash    common.Hash `json:"newHash"`
+	StateRoot  common.Hash `json:"stateRoot"`
+	// Timestamp is the block timestamp
+	Timestamp uint64 `json:"timestamp"`
+	// QueueIndex is the queue index of the last enqueued transaction up to
+	// and including this block
+	QueueIndex *uint64 `json:"queueIndex,omitempty"`
+
+	// Decision and Reason are only set on failure records, which are not
+	// checkpoints
+	Decision string `json:"decision,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// IsCheckpoint reports whether the entry records a new engine head
+func (e *Entry) IsCheckpoint() bool {
+	return e.Decision == ""
+}
+
+// Journal is an append-only file of checkpoints, one JSON entry per line
+type Journal struct {
+	path string
+	file *os.File
+	last *Entry
+	// decision is the last failure record, if no checkpoint follows it
+	decision *Entry
+}
+
+// Open loads the journal at path, creating it if it does not exist. A partially
+// written trailing entry, left behind by a crash, is discarded.
+func Open(path string) (*Journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Failed to read journal: %v", err)
+	}
+
+	var last, decision *Entry
+	offset := 0
+	for offset < len(data) {
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			log.Warn("Discarding incomplete journal entry", "path", path, "offset", offset)
+			break
+		}
+		line := data[offset : offset+end]
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry Entry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return nil, fmt.Errorf("Failed to decode journal entry at offset %d: %v", offset, err)
+			}
+			if entry.IsCheckpoint() {
+				last, decision = &entry, nil
+			} else {
+				decision = &entry
+			}
+		}
+		offset += end + 1
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open journal: %v", err)
+	}
+	if err := file.Truncate(int64(offset)); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Failed to truncate journal: %v", err)
+	}
+	if _, err := file.Seek(int64(offset), 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Failed to seek journal: %v", err)
+	}
+
+	return &Journal{path: path, file: file, last: last, decision: decision}, nil
+}
+
+// Last returns the most recent checkpoint, or nil if there is none
+func (j *Journal) Last() *Entry {
+	return j.last
+}
+
+// Append writes entry to the journal and syncs it to disk
+func (j *Journal) Append(entry *Entry) error {
+	if err := j.write(entry); err != nil {
+		return err
+	}
+	if entry.IsCheckpoint() {
+		j.last, j.decision = entry, nil
+	} else {
+		j.decision = entry
+	}
+	return nil
+}
+
+// LastDecision returns the most recent failure record, or nil if a checkpoint
+// was written after it
+func (j *Journal) LastDecision() *Entry {
+	return j.decision
+}
+
+// RecordDecision writes a failure record for the block with the given number
+func (j *Journal) RecordDecision(number uint64, decision string, reason string) error {
+	return j.Append(&Entry{
+		Number:   number,
+		Decision: decision,
+		Reason:   reason,
+	})
+}
+
+func (j *Journal) write(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("Failed to encode journal entry: %v", err)
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("Failed to write journal entry: %v", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("Failed to sync journal: %v", err)
+	}
+	return nil
+}
+
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
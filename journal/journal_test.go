@@ -0,0 +1,153 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func checkpoint(number uint64) *Entry {
+	return &Entry{
+		Number:     number,
+		LegacyHash: common.BigToHash(common.Big1),
+		NewHash:    common.BigToHash(common.Big2),
+		StateRoot:  common.BigToHash(common.Big3),
+		Timestamp:  1000 + number,
+	}
+}
+
+func openJournal(t *testing.T, path string) *Journal {
+	t.Helper()
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { j.Close() })
+	return j
+}
+
+func TestOpenCreatesEmptyJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j := openJournal(t, path)
+	if last := j.Last(); last != nil {
+		t.Fatalf("Last of a new journal = %+v, want nil", last)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("journal file was not created: %v", err)
+	}
+}
+
+func TestLastSkipsDecisionRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j := openJournal(t, path)
+
+	if err := j.Append(checkpoint(1)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := j.Append(checkpoint(2)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := j.RecordDecision(3, "retry", "state root mismatch"); err != nil {
+		t.Fatalf("RecordDecision: %v", err)
+	}
+	if err := j.Append(&Entry{Number: 3, Decision: "halt", Reason: "receipts root mismatch"}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if last := j.Last(); last == nil || last.Number != 2 {
+		t.Fatalf("Last = %+v, want checkpoint 2", last)
+	}
+	j.Close()
+
+	reopened := openJournal(t, path)
+	last := reopened.Last()
+	if last == nil || *last != *checkpoint(2) {
+		t.Fatalf("Last after reopening = %+v, want %+v", last, checkpoint(2))
+	}
+}
+
+func TestOpenDiscardsPartialTrailingEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j := openJournal(t, path)
+	if err := j.Append(checkpoint(1)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := j.Append(checkpoint(2)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	j.Close()
+
+	// Simulate a crash in the middle of writing the third entry
+	complete, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial := append(append([]byte{}, complete...), []byte(`{"number":3,"legacyHash":"0x`)...)
+	if err := os.WriteFile(path, partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := openJournal(t, path)
+	if last := reopened.Last(); last == nil || last.Number != 2 {
+		t.Fatalf("Last = %+v, want checkpoint 2", last)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(complete) {
+		t.Fatalf("journal was not truncated to its complete entries:\n%s", data)
+	}
+
+	// Entries written after recovery must start on a fresh line
+	if err := reopened.Append(checkpoint(3)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	reopened.Close()
+	if last := openJournal(t, path).Last(); last == nil || last.Number != 3 {
+		t.Fatalf("Last after appending = %+v, want checkpoint 3", last)
+	}
+}
+
+func TestOpenRejectsCorruptEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	if err := os.WriteFile(path, []byte("{\"number\":1}\nnot json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Open(path)
+	if err == nil || !strings.Contains(err.Error(), "offset 13") {
+		t.Fatalf("Open = %v, want a decode error at offset 13", err)
+	}
+}
+
+func TestLastDecisionClearedByCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j := openJournal(t, path)
+
+	if err := j.Append(checkpoint(1)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if decision := j.LastDecision(); decision != nil {
+		t.Fatalf("LastDecision = %+v, want nil", decision)
+	}
+	if err := j.RecordDecision(2, "halt", "state root mismatch"); err != nil {
+		t.Fatalf("RecordDecision: %v", err)
+	}
+	if decision := j.LastDecision(); decision == nil || decision.Number != 2 || decision.Decision != "halt" {
+		t.Fatalf("LastDecision = %+v, want halt on block 2", decision)
+	}
+	j.Close()
+
+	reopened := openJournal(t, path)
+	if decision := reopened.LastDecision(); decision == nil || decision.Number != 2 || decision.Reason != "state root mismatch" {
+		t.Fatalf("LastDecision after reopening = %+v, want halt on block 2", decision)
+	}
+	if err := reopened.Append(checkpoint(2)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if decision := reopened.LastDecision(); decision != nil {
+		t.Fatalf("LastDecision after a checkpoint = %+v, want nil", decision)
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/Boyuan-Chen/v3-migration/commands"
 	"github.com/Boyuan-Chen/v3-migration/config"
 	"github.com/Boyuan-Chen/v3-migration/flags"
 	"github.com/Boyuan-Chen/v3-migration/migration"
@@ -21,6 +25,10 @@ var (
 func main() {
 	app := cli.NewApp()
 	app.Flags = flags.Flags
+	app.Commands = []cli.Command{
+		commands.DumpTuringCommand,
+		commands.VerifyBodiesCommand,
+	}
 
 	app.Version = GitVersion + "-" + params.VersionWithCommit(GitCommit, GitDate)
 	app.Name = "boba-v3-migration"
@@ -34,7 +42,12 @@ func main() {
 		}
 
 		config := config.NewConfig(ctx)
-		m, err := migration.NewMigration(config)
+
+		// Cancel every in-flight RPC call on SIGINT or SIGTERM
+		rootCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		m, err := migration.NewMigration(rootCtx, config)
 		if err != nil {
 			return err
 		}
@@ -43,9 +56,10 @@ func main() {
 			return err
 		}
 
-		m.Wait()
+		err = m.Wait()
+		m.LogSummary()
 
-		return nil
+		return err
 	}
 
 	err := app.Run(os.Args)
@@ -0,0 +1,54 @@
+package migration
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// watchJWTSecret reloads the JWT secret on SIGHUP and, unless the reload
+// interval is 0, whenever the modification time of the secret file changes.
+// Clients rebuild their connection on their next call.
+func (m *Migration) watchJWTSecret() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var poll <-chan time.Time
+	if m.config.JWTReloadInterval > 0 {
+		ticker := time.NewTicker(time.Duration(m.config.JWTReloadInterval) * time.Second)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	modTime := m.jwtSecretModTime()
+
+	for {
+		select {
+		case <-hup:
+			log.Info("Reloading JWT secret", "reason", "SIGHUP")
+		case <-poll:
+			latest := m.jwtSecretModTime()
+			if latest.Equal(modTime) {
+				continue
+			}
+			modTime = latest
+			log.Info("Reloading JWT secret", "reason", "file changed")
+		case <-m.stop:
+			return
+		}
+		if _, err := m.jwtSecret.Reload(); err != nil {
+			log.Warn("Failed to reload JWT secret, keeping the current one", "message", err)
+		}
+	}
+}
+
+func (m *Migration) jwtSecretModTime() time.Time {
+	info, err := os.Stat(m.config.JWTSecretPath)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
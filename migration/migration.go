@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/big"
+	"sync"
 	"time"
 
 	"github.com/Boyuan-Chen/v3-migration/config"
 	"github.com/Boyuan-Chen/v3-migration/engineapi"
+	"github.com/Boyuan-Chen/v3-migration/journal"
 	"github.com/Boyuan-Chen/v3-migration/mine"
 	"github.com/Boyuan-Chen/v3-migration/rpc"
 	"github.com/ethereum/go-ethereum/log"
@@ -19,16 +22,31 @@ var (
 
 	errNoL2LegacyEndpoint = errors.New("no l2 legacy endpoint provided")
 	errNoJWTSecretPath    = errors.New("no JWT secret path provided")
+	errNoJournalPath      = errors.New("no journal path provided")
+
+	errInvalidBlockRange    = errors.New("invalid block range")
+	errInvalidFailurePolicy = errors.New("invalid failure policy")
+	errInvalidMineMode      = errors.New("invalid mine mode")
+	errInvalidForkchoiceLag = errors.New("invalid forkchoice lag")
+	errInvalidForkTimes     = errors.New("invalid fork times")
+	errInterrupted          = errors.New("migration interrupted")
 )
 
 type Migration struct {
-	config *config.Config
-	ctx    context.Context
-	stop   chan struct{}
-	miner  *mine.Miner
+	config      *config.Config
+	ctx         context.Context
+	stop        chan struct{}
+	stopOnce    sync.Once
+	err         error
+	miner       *mine.Miner
+	checkpoints *journal.Journal
+	jwtSecret   *rpc.JWTSecret
+	startTime   time.Time
 }
 
-func NewMigration(cfg *config.Config) (*Migration, error) {
+// NewMigration creates a migration whose RPC calls are all bound to ctx.
+// Cancelling ctx aborts the block being mined and stops the migration.
+func NewMigration(ctx context.Context, cfg *config.Config) (*Migration, error) {
 	if cfg.L2PrivateEndpoint == defaultL2PrivateEndpoint {
 		log.Info("L2 private endpoint is set to the default value.", "endpoint", defaultL2PrivateEndpoint)
 	}
@@ -41,66 +59,171 @@ func NewMigration(cfg *config.Config) (*Migration, error) {
 	if cfg.JWTSecretPath == "" {
 		return nil, fmt.Errorf("JWT secret path is not set: %w", errNoJWTSecretPath)
 	}
+	if cfg.JournalPath == "" {
+		return nil, fmt.Errorf("journal path is not set: %w", errNoJournalPath)
+	}
+	switch cfg.FailurePolicy {
+	case mine.FailurePolicyRetry, mine.FailurePolicyRollback, mine.FailurePolicyHalt:
+	default:
+		return nil, fmt.Errorf("unknown failure policy %q: %w", cfg.FailurePolicy, errInvalidFailurePolicy)
+	}
+	switch cfg.MineMode {
+	case mine.MineModeBuild:
+	case mine.MineModeDirect:
+		if cfg.DryRun {
+			return nil, fmt.Errorf("a dry run needs the engine to build the block, use mine mode %q: %w", mine.MineModeBuild, errInvalidMineMode)
+		}
+	default:
+		return nil, fmt.Errorf("unknown mine mode %q: %w", cfg.MineMode, errInvalidMineMode)
+	}
+	if cfg.SafeDepth < 0 || cfg.FinalizedDepth < 0 || cfg.FinalizeInterval < 0 {
+		return nil, fmt.Errorf("safe depth, finalized depth and finalize interval must not be negative: %w", errInvalidForkchoiceLag)
+	}
+	// Engines refuse to move the finalized block backwards, so a block that
+	// may be rolled back must not be finalized when it becomes the head
+	if cfg.FailurePolicy != mine.FailurePolicyHalt && cfg.FinalizedDepth == 0 && !cfg.FinalizeAtHardForkOnly {
+		return nil, fmt.Errorf("failure policy %q rewinds bad blocks, which needs a finalized depth of at least 1: %w", cfg.FailurePolicy, errInvalidForkchoiceLag)
+	}
+	if cfg.CancunTime != nil && (cfg.ShanghaiTime == nil || *cfg.ShanghaiTime > *cfg.CancunTime) {
+		return nil, fmt.Errorf("cancun time requires an earlier or equal shanghai time: %w", errInvalidForkTimes)
+	}
+	if cfg.FromBlock < 0 || cfg.ToBlock < 0 {
+		return nil, fmt.Errorf("block range must not be negative: %w", errInvalidBlockRange)
+	}
+	if cfg.ToBlock > cfg.BobaHardForkBlock {
+		return nil, fmt.Errorf("to block %d is beyond the hard fork block %d: %w", cfg.ToBlock, cfg.BobaHardForkBlock, errInvalidBlockRange)
+	}
+	if cfg.FromBlock > cfg.BobaHardForkBlock || (cfg.ToBlock > 0 && cfg.FromBlock > cfg.ToBlock) {
+		return nil, fmt.Errorf("from block %d is beyond the last block to migrate: %w", cfg.FromBlock, errInvalidBlockRange)
+	}
 
-	JWTSecret, err := cfg.GetJWTSecret()
+	// The secret is reloaded when the file changes, so clients pick up a
+	// rotated secret without a restart
+	JWTSecret, err := rpc.NewJWTSecret(cfg.GetJWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	l2PublicRpc, err := rpc.NewAuthRpcClient(ctx, cfg.L2PublicEndpoint, JWTSecret)
 	if err != nil {
 		return nil, err
 	}
-	l2PublicRpc, err := rpc.NewRpcClient(cfg.L2PublicEndpoint, *JWTSecret)
+	l2LegacyRpc, err := rpc.NewAuthRpcClient(ctx, cfg.L2LegacyEndpoint, JWTSecret)
 	if err != nil {
 		return nil, err
 	}
-	l2LegacyRpc, err := rpc.NewRpcClient(cfg.L2LegacyEndpoint, *JWTSecret)
+	l2PrivateRpc, err := rpc.NewAuthRpcClient(ctx, cfg.L2PrivateEndpoint, JWTSecret)
 	if err != nil {
 		return nil, err
 	}
-	l2PrivateRpc, err := rpc.NewRpcClient(cfg.L2PrivateEndpoint, *JWTSecret)
+	l2EngineAPI, err := engineapi.NewEngineAPI(ctx, l2PrivateRpc, cfg)
 	if err != nil {
 		return nil, err
 	}
-	l2EngineAPI, err := engineapi.NewEngineAPI(l2PrivateRpc, cfg)
+	if cfg.MineMode == mine.MineModeDirect && cfg.ShanghaiTime != nil {
+		if err := checkDirectMineRange(ctx, cfg, l2LegacyRpc, l2EngineAPI); err != nil {
+			return nil, err
+		}
+	}
+
+	checkpoints, err := journal.Open(cfg.JournalPath)
 	if err != nil {
 		return nil, err
 	}
 
-	miner := mine.NewMiner(l2PublicRpc, l2LegacyRpc, l2EngineAPI, cfg)
+	miner := mine.NewMiner(l2PublicRpc, l2LegacyRpc, l2EngineAPI, checkpoints, cfg)
 
 	migration := &Migration{
-		config: cfg,
-		ctx:    context.Background(),
-		stop:   make(chan struct{}),
-		miner:  miner,
+		config:      cfg,
+		ctx:         ctx,
+		stop:        make(chan struct{}),
+		miner:       miner,
+		checkpoints: checkpoints,
+		jwtSecret:   JWTSecret,
 	}
 
 	return migration, nil
 }
 
+// checkDirectMineRange refuses direct mining of blocks from Shanghai on. Their
+// header has fields the legacy header lacks, so a payload carrying the legacy
+// block hash is always rejected. Timestamps only grow, so checking the last
+// block of the range is enough.
+func checkDirectMineRange(ctx context.Context, cfg *config.Config, l2LegacyRpc *rpc.RpcClient, l2EngineAPI *engineapi.EngineAPI) error {
+	last := cfg.BobaHardForkBlock
+	if cfg.ToBlock > 0 {
+		last = cfg.ToBlock
+	}
+	lastBlock, err := l2LegacyRpc.GetBlock(ctx, big.NewInt(int64(last)))
+	if err != nil {
+		return err
+	}
+	if version := l2EngineAPI.PayloadVersion(uint64(lastBlock.Time)); version >= engineapi.V2 {
+		return fmt.Errorf("block %d needs %s payloads, which can't keep the legacy block hash, use mine mode %q: %w", last, version, mine.MineModeBuild, errInvalidMineMode)
+	}
+	return nil
+}
+
 func (m *Migration) Start() error {
+	m.startTime = time.Now()
+	if err := m.miner.VerifyCheckpoint(m.ctx); err != nil {
+		return err
+	}
+	if err := m.miner.VerifyStartBlock(m.ctx); err != nil {
+		return err
+	}
 	go m.Loop()
+	go m.watchJWTSecret()
 	return nil
 }
 
 func (m *Migration) Stop() {
-	close(m.stop)
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
 }
 
-func (m *Migration) Wait() {
+// Wait blocks until the migration loop has exited and returns the reason it
+// stopped, if any
+func (m *Migration) Wait() error {
 	<-m.stop
+	return m.err
 }
 
 // Loop is the main logic of the migration
 func (m *Migration) Loop() {
+	defer m.Stop()
+	defer m.checkpoints.Close()
+
 	timer := time.NewTicker(time.Duration(m.config.EpochLengthSecond) * time.Second)
 	defer timer.Stop()
 	for {
 		select {
 		case <-timer.C:
 			log.Trace("polling", "time", time.Now())
-			if err := m.miner.MineBlock(); err != nil {
+			err := m.miner.MineBlock(m.ctx)
+			if m.config.DryRun && m.ctx.Err() == nil {
+				// A dry run validates a single block and stops either way
+				if !errors.Is(err, mine.ErrDryRunComplete) && !errors.Is(err, mine.ErrMigrationComplete) {
+					m.err = err
+				}
+				return
+			}
+			if errors.Is(err, mine.ErrHalted) {
+				log.Error("Migration halted", "message", err)
+				m.err = err
+				return
+			}
+			if errors.Is(err, mine.ErrMigrationComplete) {
+				log.Info("Migration complete", "lastBlock", m.miner.Summary().LastNumber)
+				return
+			}
+			if err != nil && m.ctx.Err() == nil {
 				log.Error("cannot mine new block", "message", err)
 			}
 		case <-m.ctx.Done():
-			m.Stop()
+			log.Info("Shutting down migration", "reason", m.ctx.Err())
+			m.err = errInterrupted
+			return
 		}
 	}
 }
@@ -108,3 +231,21 @@ func (m *Migration) Loop() {
 func (m *Migration) Mine() error {
 	return nil
 }
+
+// LogSummary reports what has been migrated. It must be called after Wait.
+func (m *Migration) LogSummary() {
+	summary := m.miner.Summary()
+	log.Info("Migration summary",
+		"blocksMined", summary.BlocksMined,
+		"lastBlockNumber", summary.LastNumber,
+		"lastBlockHash", summary.LastHash,
+		"stateRoot", summary.StateRoot,
+		"receiptsRoot", summary.ReceiptsRoot,
+		"finalizedBlockNumber", summary.FinalizedNumber,
+		"finalizedBlockHash", summary.FinalizedHash,
+		"sequencerTransactions", summary.SequencerTransactions,
+		"enqueuedTransactions", summary.EnqueuedTransactions,
+		"turingTransactions", summary.TuringTransactions,
+		"elapsed", time.Since(m.startTime),
+	)
+}
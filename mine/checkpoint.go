@@ -0,0 +1,81 @@
+package mine
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/Boyuan-Chen/v3-migration/journal"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// VerifyCheckpoint checks that the engine head is where the journal says we
+// left it. A head exactly one block ahead of the journal means the process
+// stopped between the forkchoice update and the journal write, which only
+// happens once the block is verified; that block is checked against the
+// legacy chain and journaled, unless the failure policy halted on it. Anything
+// else means a half-applied block or an engine that was rewound, and
+// migration must not continue.
+func (m *Miner) VerifyCheckpoint(ctx context.Context) error {
+	latestBlock, err := m.l2PublicRpc.GetLatestBlock(ctx)
+	if err != nil {
+		return err
+	}
+	head := uint64(latestBlock.Number)
+
+	if decision := m.journal.LastDecision(); decision != nil && decision.Decision == decisionHalt && decision.Number == head {
+		return fmt.Errorf("engine head %d failed verification and was halted on (%s), roll the engine back to block %d before resuming", head, decision.Reason, head-1)
+	}
+
+	last := m.journal.Last()
+	if last == nil {
+		log.Info("Journal is empty, starting from engine head", "blockNumber", head, "hash", latestBlock.Hash)
+		return nil
+	}
+	m.queue.commit(last.QueueIndex)
+
+	switch {
+	case head < last.Number:
+		return fmt.Errorf("engine head %d is behind the last checkpoint %d, the engine was rewound", head, last.Number)
+	case head == last.Number:
+		if latestBlock.Hash != last.NewHash {
+			return fmt.Errorf("engine head hash %s does not match checkpoint hash %s at block %d", latestBlock.Hash, last.NewHash, head)
+		}
+		if latestBlock.Root != last.StateRoot {
+			return fmt.Errorf("engine head state root %s does not match checkpoint state root %s at block %d", latestBlock.Root, last.StateRoot, head)
+		}
+	case head == last.Number+1:
+		if latestBlock.ParentHash != last.NewHash {
+			return fmt.Errorf("engine head parent %s does not match checkpoint hash %s", latestBlock.ParentHash, last.NewHash)
+		}
+		legacyBlock, err := m.l2LegacyRpc.GetLegacyBlock(ctx, new(big.Int).SetUint64(head))
+		if err != nil {
+			return err
+		}
+		if legacyBlock == nil {
+			return fmt.Errorf("legacy block %d not found", head)
+		}
+		version := m.l2PrivateRpc.PayloadVersion(uint64(latestBlock.Time))
+		if diffs := compareHeaders(legacyBlock, latestBlock, last.NewHash, version); len(diffs) > 0 {
+			return fmt.Errorf("unjournaled engine head %d does not match the legacy block (%s), it may be half-applied", head, diffs[0])
+		}
+		log.Warn("Journaling engine head that was committed before the last shutdown", "blockNumber", head, "hash", latestBlock.Hash)
+		queueIndex := lastQueueIndex(last.QueueIndex, legacyBlock.Transactions)
+		if err := m.journal.Append(&journal.Entry{
+			Number:     head,
+			LegacyHash: legacyBlock.Hash,
+			NewHash:    latestBlock.Hash,
+			StateRoot:  latestBlock.Root,
+			Timestamp:  uint64(latestBlock.Time),
+			QueueIndex: queueIndex,
+		}); err != nil {
+			return err
+		}
+		m.queue.commit(queueIndex)
+	default:
+		return fmt.Errorf("engine head %d is %d blocks ahead of the last checkpoint %d", head, head-last.Number, last.Number)
+	}
+
+	log.Info("Engine head matches the journal", "blockNumber", head, "hash", latestBlock.Hash)
+	return nil
+}
@@ -0,0 +1,190 @@
+package mine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Boyuan-Chen/v3-migration/engineapi"
+	"github.com/Boyuan-Chen/v3-migration/journal"
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/Boyuan-Chen/v3-migration/verify"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// FailurePolicyRetry rolls back a committed bad block and retries it
+	FailurePolicyRetry = "retry"
+	// FailurePolicyRollback rolls back a committed bad block and halts
+	FailurePolicyRollback = "rollback"
+	// FailurePolicyHalt halts without touching the engine
+	FailurePolicyHalt = "halt"
+)
+
+const (
+	decisionRetry    = "retry"
+	decisionRollback = "rollback"
+	decisionHalt     = "halt"
+)
+
+// fieldPayloadStatus is the mismatch field of a payload the engine rejected
+const fieldPayloadStatus = "payload status"
+
+// ErrHalted is returned by MineBlock when the failure policy stops the migration
+var ErrHalted = errors.New("migration halted")
+
+// MismatchError reports a migrated block that differs from the legacy block
+type MismatchError struct {
+	BlockNumber uint64
+	Field       string
+	Legacy      interface{}
+	Migrated    interface{}
+	// Committed is set if the block had already become the engine head
+	Committed bool
+}
+
+func newMismatchError(legacyBlock *rpc.LegacyBlock, field string, legacy interface{}, migrated interface{}, committed bool) *MismatchError {
+	return &MismatchError{
+		BlockNumber: uint64(legacyBlock.Number),
+		Field:       field,
+		Legacy:      legacy,
+		Migrated:    migrated,
+		Committed:   committed,
+	}
+}
+
+// IsRootMismatch reports whether execution diverged, as opposed to the block
+// being assembled differently
+func (e *MismatchError) IsRootMismatch() bool {
+	return e.Field == verify.FieldStateRoot || e.Field == verify.FieldReceiptsRoot
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("block %d %s mismatch: legacy %v, migrated %v", e.BlockNumber, e.Field, e.Legacy, e.Migrated)
+}
+
+// handleMismatch applies the failure policy to a block that did not match the
+// legacy block. parent is the last verified head. A nil return means the
+// block should be mined again.
+func (m *Miner) handleMismatch(ctx context.Context, parent *rpc.Block, mismatch *MismatchError) error {
+	log.Warn("Migrated block does not match legacy block", "blockNumber", mismatch.BlockNumber, "field", mismatch.Field, "committed", mismatch.Committed, "policy", m.config.FailurePolicy)
+
+	if mismatch.Committed && m.config.FailurePolicy != FailurePolicyHalt {
+		m.decide(mismatch.BlockNumber, decisionRollback, mismatch.Error())
+		if err := m.rollback(ctx, parent); err != nil {
+			m.decide(mismatch.BlockNumber, decisionHalt, err.Error())
+			return fmt.Errorf("%w: %v", ErrHalted, err)
+		}
+	}
+
+	if m.config.FailurePolicy != FailurePolicyRetry {
+		m.decide(mismatch.BlockNumber, decisionHalt, mismatch.Error())
+		return fmt.Errorf("%w: %v", ErrHalted, mismatch)
+	}
+
+	if m.retryBlock != mismatch.BlockNumber {
+		m.retryBlock = mismatch.BlockNumber
+		m.retries = 0
+	}
+	if m.retries >= m.config.MaxRetries {
+		reason := fmt.Sprintf("%d retries exhausted: %v", m.retries, mismatch)
+		m.decide(mismatch.BlockNumber, decisionHalt, reason)
+		return fmt.Errorf("%w: %s", ErrHalted, reason)
+	}
+	m.retries++
+	backoff := time.Duration(m.config.RetryBackoff) * time.Second << (m.retries - 1)
+	m.decide(mismatch.BlockNumber, decisionRetry, fmt.Sprintf("attempt %d of %d after %s: %v", m.retries, m.config.MaxRetries, backoff, mismatch))
+
+	select {
+	case <-time.After(backoff):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rollback moves the forkchoice back to parent and checks that the engine
+// followed
+func (m *Miner) rollback(ctx context.Context, parent *rpc.Block) error {
+	fc, err := m.forkchoiceState(ctx, uint64(parent.Number), parent.Hash)
+	if err != nil {
+		return err
+	}
+	if _, err := m.l2PrivateRpc.ForkchoiceUpdate(ctx, fc, nil); err != nil {
+		return fmt.Errorf("failed to roll back to block %d: %w", uint64(parent.Number), err)
+	}
+	latestBlock, err := m.l2PublicRpc.GetLatestBlock(ctx)
+	if err != nil {
+		return err
+	}
+	if latestBlock.Hash != parent.Hash {
+		return fmt.Errorf("engine head is %s after rolling back to %s", latestBlock.Hash, parent.Hash)
+	}
+
+	// Keep the journal in step with the engine head
+	legacyHash, err := m.legacyHash(ctx, uint64(parent.Number))
+	if err != nil {
+		return err
+	}
+	if err := m.journal.Append(&journal.Entry{
+		Number:     uint64(parent.Number),
+		LegacyHash: legacyHash,
+		NewHash:    parent.Hash,
+		StateRoot:  parent.Root,
+		Timestamp:  uint64(parent.Time),
+		QueueIndex: m.queue.lastIndex,
+	}); err != nil {
+		return err
+	}
+	log.Info("Rolled back engine head", "blockNumber", uint64(parent.Number), "hash", parent.Hash)
+	return nil
+}
+
+// legacyHash returns the legacy hash of a migrated block, from its checkpoint
+// if it is the last one or from the legacy chain otherwise
+func (m *Miner) legacyHash(ctx context.Context, number uint64) (common.Hash, error) {
+	if last := m.journal.Last(); last != nil && last.Number == number {
+		return last.LegacyHash, nil
+	}
+	legacyBlock, err := m.l2LegacyRpc.GetLegacyBlock(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if legacyBlock == nil {
+		return common.Hash{}, fmt.Errorf("legacy block %d not found", number)
+	}
+	return legacyBlock.Hash, nil
+}
+
+// decide logs a failure handling decision and records it in the journal
+func (m *Miner) decide(number uint64, decision string, reason string) {
+	log.Warn("Failure policy decision", "blockNumber", number, "decision", decision, "reason", reason)
+	if err := m.journal.RecordDecision(number, decision, reason); err != nil {
+		log.Error("Failed to record decision", "message", err)
+	}
+}
+
+// isFatalEngineError reports whether the engine refused a call in a way that
+// retrying the block can't fix: the JWT was rejected, or the request itself
+// is wrong. Unknown payloads, internal errors and transport failures are
+// retried.
+func isFatalEngineError(err error) bool {
+	for _, fatal := range []error{
+		engineapi.ErrUnauthorized,
+		engineapi.ErrParse,
+		engineapi.ErrInvalidRequest,
+		engineapi.ErrMethodNotFound,
+		engineapi.ErrInvalidParams,
+		engineapi.ErrInvalidForkchoiceState,
+		engineapi.ErrInvalidPayloadAttributes,
+		engineapi.ErrUnsupportedFork,
+	} {
+		if errors.Is(err, fatal) {
+			return true
+		}
+	}
+	return false
+}
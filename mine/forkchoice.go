@@ -0,0 +1,93 @@
+package mine
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/Boyuan-Chen/v3-migration/engineapi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// forkchoiceState builds the forkchoice state for a new head. The safe and
+// finalized blocks trail the head by the configured depths, so that a bad
+// block can still be rewound by the engine. A zero finalized hash tells the
+// engine that nothing is finalized yet. The finalized block never moves
+// backwards: rolling back to an older head keeps the last one sent, and the
+// safe block is kept at or above it.
+func (m *Miner) forkchoiceState(ctx context.Context, headNumber uint64, headHash common.Hash) (*engineapi.ForkchoiceState, error) {
+	m.rememberHash(headNumber, headHash)
+
+	safeNumber := trail(headNumber, m.config.SafeDepth)
+	safeHash, err := m.blockHash(ctx, safeNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	var finalizedHash common.Hash
+	if !m.config.FinalizeAtHardForkOnly {
+		finalizedNumber := trail(headNumber, m.config.FinalizedDepth)
+		if finalizedNumber > safeNumber {
+			finalizedNumber = safeNumber
+		}
+		if interval := uint64(m.config.FinalizeInterval); interval > 0 {
+			finalizedNumber -= finalizedNumber % interval
+		}
+		if m.finalizedHash != (common.Hash{}) && finalizedNumber <= m.finalizedNumber {
+			finalizedNumber, finalizedHash = m.finalizedNumber, m.finalizedHash
+		} else {
+			finalizedHash, err = m.blockHash(ctx, finalizedNumber)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if safeNumber < finalizedNumber {
+			safeHash = finalizedHash
+		}
+		m.finalizedNumber, m.finalizedHash = finalizedNumber, finalizedHash
+		m.forgetHashesBelow(finalizedNumber)
+	} else {
+		m.forgetHashesBelow(safeNumber)
+	}
+
+	return &engineapi.ForkchoiceState{
+		HeadBlockHash:      headHash,
+		SafeBlockHash:      safeHash,
+		FinalizedBlockHash: finalizedHash,
+	}, nil
+}
+
+func trail(head uint64, depth int) uint64 {
+	if uint64(depth) > head {
+		return 0
+	}
+	return head - uint64(depth)
+}
+
+// blockHash returns the hash of a migrated block, from the hashes seen by
+// this miner or from the public endpoint
+func (m *Miner) blockHash(ctx context.Context, number uint64) (common.Hash, error) {
+	if hash, ok := m.hashes[number]; ok {
+		return hash, nil
+	}
+	block, err := m.l2PublicRpc.GetBlock(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	m.rememberHash(number, block.Hash)
+	return block.Hash, nil
+}
+
+func (m *Miner) rememberHash(number uint64, hash common.Hash) {
+	if m.hashes == nil {
+		m.hashes = make(map[uint64]common.Hash)
+	}
+	m.hashes[number] = hash
+}
+
+func (m *Miner) forgetHashesBelow(number uint64) {
+	for n := range m.hashes {
+		if n < number {
+			delete(m.hashes, n)
+		}
+	}
+}
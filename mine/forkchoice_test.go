@@ -0,0 +1,61 @@
+package mine
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/Boyuan-Chen/v3-migration/config"
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestForkchoiceStateKeepsFinalizedOnRollback(t *testing.T) {
+	// The public endpoint knows every block by a hash derived from its number
+	public := newFakeRpcClient(func(ctx context.Context, method string, args []interface{}) (interface{}, error) {
+		if method != "eth_getBlockByNumber" {
+			return nil, errUnexpectedCall(method)
+		}
+		number, err := hexutil.DecodeBig(args[0].(string))
+		if err != nil {
+			return nil, err
+		}
+		return &rpc.Block{Number: hexutil.Uint64(number.Uint64()), Hash: common.BigToHash(number)}, nil
+	})
+	m := NewMiner(public, nil, nil, nil, &config.Config{SafeDepth: 1, FinalizedDepth: 1})
+	ctx := context.Background()
+
+	head := common.HexToHash("0x10")
+	fc, err := m.forkchoiceState(ctx, 10, head)
+	if err != nil {
+		t.Fatalf("forkchoiceState: %v", err)
+	}
+	finalized := common.BigToHash(big.NewInt(9))
+	if fc.FinalizedBlockHash != finalized || fc.SafeBlockHash != finalized {
+		t.Fatalf("forkchoice state for block 10 = %+v, want block 9 safe and finalized", fc)
+	}
+
+	// Rolling back to block 9 must not move the finalized block backwards,
+	// and the safe block must not fall behind it
+	fc, err = m.forkchoiceState(ctx, 9, finalized)
+	if err != nil {
+		t.Fatalf("forkchoiceState: %v", err)
+	}
+	if fc.HeadBlockHash != finalized || fc.SafeBlockHash != finalized || fc.FinalizedBlockHash != finalized {
+		t.Fatalf("forkchoice state after rolling back = %+v, want block 9 as head, safe and finalized", fc)
+	}
+
+	// Mining block 10 again finalizes newer blocks again
+	retried := common.HexToHash("0x1010")
+	if _, err := m.forkchoiceState(ctx, 10, retried); err != nil {
+		t.Fatalf("forkchoiceState: %v", err)
+	}
+	fc, err = m.forkchoiceState(ctx, 11, common.HexToHash("0x11"))
+	if err != nil {
+		t.Fatalf("forkchoiceState: %v", err)
+	}
+	if fc.FinalizedBlockHash != retried {
+		t.Fatalf("finalized hash for block 11 = %s, want %s", fc.FinalizedBlockHash, retried)
+	}
+}
@@ -1,169 +1,534 @@
 package mine
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/Boyuan-Chen/v3-migration/config"
+	"github.com/Boyuan-Chen/v3-migration/diagnostic"
 	"github.com/Boyuan-Chen/v3-migration/engineapi"
+	"github.com/Boyuan-Chen/v3-migration/journal"
 	"github.com/Boyuan-Chen/v3-migration/rpc"
 	"github.com/Boyuan-Chen/v3-migration/transaction"
+	"github.com/Boyuan-Chen/v3-migration/verify"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 )
 
+const (
+	// MineModeBuild has the engine build every block and compares it with
+	// the legacy block before executing it
+	MineModeBuild = "build"
+	// MineModeDirect executes the legacy block as is, saving the build round
+	// trip
+	MineModeDirect = "direct"
+)
+
+// minedBlockPollInterval is how often the public endpoint is asked for a
+// block the engine has just made its head
+const minedBlockPollInterval = 100 * time.Millisecond
+
+// ErrMigrationComplete is returned by MineBlock once the hard fork block has
+// been migrated and finalized
+var ErrMigrationComplete = errors.New("migration complete")
+
+// ErrDryRunComplete is returned by MineBlock in dry-run mode once the next
+// block has been built and matches the legacy block
+var ErrDryRunComplete = errors.New("dry run complete")
+
 type Miner struct {
 	l2PublicRpc  *rpc.RpcClient
 	l2LegacyRpc  *rpc.RpcClient
 	l2PrivateRpc *engineapi.EngineAPI
+	journal      *journal.Journal
 	config       *config.Config
+	prefetcher   *prefetcher
+	summary      Summary
+	hashes       map[uint64]common.Hash
+	// finalizedNumber and finalizedHash are the last finalized block put in
+	// a forkchoice state
+	finalizedNumber uint64
+	finalizedHash   common.Hash
+	retryBlock      uint64
+	retries         int
+	queue           queueTracker
 }
 
-func NewMiner(l2PublicRpc *rpc.RpcClient, l2LegacyRpc *rpc.RpcClient, l2PrivateRpc *engineapi.EngineAPI, cfg *config.Config) *Miner {
+func NewMiner(l2PublicRpc *rpc.RpcClient, l2LegacyRpc *rpc.RpcClient, l2PrivateRpc *engineapi.EngineAPI, checkpoints *journal.Journal, cfg *config.Config) *Miner {
 	return &Miner{
 		l2PublicRpc:  l2PublicRpc,
 		l2LegacyRpc:  l2LegacyRpc,
 		l2PrivateRpc: l2PrivateRpc,
+		journal:      checkpoints,
 		config:       cfg,
 	}
 }
 
-func (m *Miner) MineBlock() error {
+// MineBlock migrates legacy blocks one after another until an error occurs,
+// the hard fork block is reached or ctx is cancelled
+func (m *Miner) MineBlock(ctx context.Context) error {
 	for {
+		select {
+		case <-ctx.Done():
+			m.stopPrefetcher()
+			return ctx.Err()
+		default:
+		}
+
 		// Get latest block
-		latestBlock, err := m.l2PublicRpc.GetLatestBlock()
+		latestBlock, err := m.l2PublicRpc.GetLatestBlock(ctx)
 		if err != nil {
 			return err
 		}
 		nextBlockNumber := uint64(latestBlock.Number) + 1
 
-		if nextBlockNumber > uint64(m.config.BobaHardForkBlock) {
-			log.Info("Hard fork block reached, stopping miner")
-			return nil
+		if nextBlockNumber > m.lastBlock() {
+			m.stopPrefetcher()
+			if m.lastBlock() != uint64(m.config.BobaHardForkBlock) {
+				log.Info("Last block of the range reached, stopping miner", "blockNumber", uint64(latestBlock.Number))
+				m.recordHead(latestBlock)
+				return ErrMigrationComplete
+			}
+			log.Info("Hard fork block reached, stopping miner", "blockNumber", uint64(latestBlock.Number))
+			if m.config.DryRun {
+				return ErrMigrationComplete
+			}
+			if err := m.finalizeHardFork(ctx, latestBlock); err != nil {
+				return err
+			}
+			return ErrMigrationComplete
 		}
 
-		legacyBlock, err := m.l2LegacyRpc.GetLegacyBlock(big.NewInt(int64(nextBlockNumber)))
+		batch, err := m.nextLegacyBatch(ctx, nextBlockNumber)
 		if err != nil {
 			return err
 		}
-		gasLimit := legacyBlock.GasLimit
-		txHash := legacyBlock.Transactions[0].Hash()
-		legacyTransaction, err := m.l2LegacyRpc.GetLegacyTransaction(txHash)
 
-		// Verify that legacy transaction has the same txHash
-		if legacyTransaction.Hash() != txHash {
-			return fmt.Errorf("legacy transaction hash does not match")
+		batch.queueIndex, err = m.queue.next(batch.number, batch.legacyTransactions)
+		if err != nil {
+			m.decide(batch.number, decisionHalt, err.Error())
+			return fmt.Errorf("%w: %v", ErrHalted, err)
 		}
 
-		// Build binary legacy transaction
-		binaryLegacyTx, err := transaction.MarshalBinary(legacyTransaction)
-		if err != nil {
+		err = m.mineLegacyBlock(ctx, latestBlock, batch)
+		if err := m.handleMineError(ctx, latestBlock, batch, err); err != nil {
 			return err
 		}
-		transactions := make([]engineapi.Data, 1)
-		transactions[0] = binaryLegacyTx
+	}
+}
 
-		// Step 1: Get payloadID
-		// engine_forkchoiceUpdatedV1 -> Get payloadID
-		fc := &engineapi.ForkchoiceState{
-			HeadBlockHash:      latestBlock.Hash,
-			SafeBlockHash:      latestBlock.Hash,
-			FinalizedBlockHash: latestBlock.Hash,
+// handleMineError writes a diagnostic bundle for a diverging block and
+// applies the failure policy to a mismatch. A nil return means mining can go
+// on.
+func (m *Miner) handleMineError(ctx context.Context, latestBlock *rpc.Block, batch *legacyBatch, err error) error {
+	var mismatch *MismatchError
+	if errors.As(err, &mismatch) {
+		// Collect evidence before the failure policy rolls the block back
+		if m.isExecutionMismatch(mismatch) {
+			m.writeDiagnostics(ctx, batch, mismatch)
 		}
-		attributes := &engineapi.PayloadAttributes{
-			Timestamp:             hexutil.Uint64(legacyBlock.Time),
-			PrevRandao:            [32]byte{},
-			SuggestedFeeRecipient: common.HexToAddress("0x4200000000000000000000000000000000000011"),
-			Transactions:          transactions,
-			NoTxPool:              true,
-			GasLimit:              &gasLimit,
+		if !m.config.DryRun {
+			err = m.handleMismatch(ctx, latestBlock, mismatch)
 		}
+	}
+	if isFatalEngineError(err) {
+		m.decide(batch.number, decisionHalt, err.Error())
+		return fmt.Errorf("%w: %v", ErrHalted, err)
+	}
+	return err
+}
+
+// isExecutionMismatch reports whether a mismatch comes from executing the
+// block. In direct mode the payload carries the legacy roots, so the engine
+// reports a diverging execution as an invalid payload.
+func (m *Miner) isExecutionMismatch(mismatch *MismatchError) bool {
+	if m.config.MineMode == MineModeDirect && mismatch.Field == fieldPayloadStatus {
+		return true
+	}
+	return mismatch.IsRootMismatch()
+}
 
-		// engine_forkchoiceUpdatedV1
-		fcUpdateRes, err := m.l2PrivateRpc.ForkchoiceUpdate(fc, attributes)
+// mineLegacyBlock rebuilds a legacy block on top of latestBlock and makes it
+// the engine head
+func (m *Miner) mineLegacyBlock(ctx context.Context, latestBlock *rpc.Block, batch *legacyBatch) error {
+	legacyBlock := batch.legacyBlock
+
+	// Build binary legacy transactions, keeping the legacy order
+	transactions := make([]engineapi.Data, len(batch.legacyTransactions))
+	for i, legacyTransaction := range batch.legacyTransactions {
+		binaryLegacyTx, err := transaction.MarshalBinary(legacyTransaction)
 		if err != nil {
 			return err
 		}
+		transactions[i] = binaryLegacyTx
+	}
 
-		// Step 2: Get executionPayload
-		// engine_getPayloadV1 -> Get executionPayload
-		executionRes, err := m.l2PrivateRpc.GetPayload(fcUpdateRes.PayloadID)
+	version := m.l2PrivateRpc.PayloadVersion(uint64(legacyBlock.Time))
+	// There is no beacon chain behind a legacy block, so from V3 on the
+	// zero root is used
+	var parentBeaconBlockRoot *common.Hash
+	if version >= engineapi.V3 {
+		parentBeaconBlockRoot = &common.Hash{}
+	}
+
+	// Steps 1 and 2: Get executionPayload, built by the engine or taken
+	// from the legacy block
+	var executionRes *engineapi.ExecutionPayload
+	if m.config.MineMode == MineModeDirect {
+		executionRes = directPayload(legacyBlock, transactions)
+	} else {
+		var err error
+		executionRes, err = m.buildPayload(ctx, latestBlock, legacyBlock, transactions, version, parentBeaconBlockRoot)
 		if err != nil {
 			return err
 		}
-		var txType types.Transaction
-		if len(executionRes.Transactions) != 1 {
-			log.Warn("Pending transaction length is not 1")
-			return fmt.Errorf("pending transaction length is not 1")
-		}
-		err = txType.UnmarshalBinary(executionRes.Transactions[0])
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal transaction: %w", err)
+	}
+	if err := verifyPayloadTransactions(executionRes, legacyBlock, batch.legacyTransactions); err != nil {
+		return err
+	}
+	// A diverging root changes the block hash too, so check the roots first
+	// to report the execution difference rather than the hash
+	if err := verifyPayloadRoots(executionRes, legacyBlock); err != nil {
+		return err
+	}
+
+	// Rebuild the header with the legacy seal, so the block hash reported
+	// by the engine doesn't have to be trusted
+	header, err := engineapi.VerifyBlockHash(executionRes, legacySeal(legacyBlock))
+	var hashErr *engineapi.BlockHashError
+	switch {
+	case errors.Is(err, engineapi.ErrUnsupportedHeader):
+		log.Debug("Skipping local block hash check", "message", err)
+	case err != nil && !errors.As(err, &hashErr):
+		return err
+	}
+	if version >= engineapi.V2 {
+		// The header can't keep the legacy hash, so compare what the
+		// payload carries instead
+		if diffs := verify.CompareForkPayload(legacyBlock, executionRes, latestBlock.Hash); len(diffs) > 0 {
+			log.Warn("Pending block is not correct", "blockNumber", uint64(executionRes.BlockNumber), "field", diffs[0].Field)
+			logFieldDiffs(diffs)
+			return newMismatchError(legacyBlock, diffs[0].Field, diffs[0].Legacy, diffs[0].Migrated, false)
 		}
-		if txType.Hash() != txHash {
-			log.Warn("Pending transaction hash is not correct", "pending", txType.Hash(), "latest", txHash)
-			return fmt.Errorf("pending transaction hash is not correct")
+	} else if executionRes.BlockHash != legacyBlock.Hash {
+		log.Warn("Pending block hash is not correct", "pending", executionRes.BlockHash, "latest", legacyBlock.Hash)
+		if header != nil {
+			logFieldDiffs(verify.CompareLocalHeader(legacyBlock, header))
+		} else {
+			logFieldDiffs(verify.ComparePayload(legacyBlock, executionRes))
 		}
-		if executionRes.BlockHash != legacyBlock.Hash {
-			log.Warn("Pending block hash is not correct", "pending", executionRes.BlockHash, "latest", legacyBlock.Hash)
-			return fmt.Errorf("pending block hash is not correct")
+		return newMismatchError(legacyBlock, "block hash", legacyBlock.Hash, executionRes.BlockHash, false)
+	}
+	if hashErr != nil {
+		log.Warn("Pending block hash is not the hash of its header", "pending", hashErr.Reported, "computed", hashErr.Computed)
+		logFieldDiffs(verify.CompareLocalHeader(legacyBlock, header))
+		return newMismatchError(legacyBlock, "computed block hash", legacyBlock.Hash, hashErr.Computed, false)
+	}
+
+	// In dry-run mode the built block is never executed, so the engine
+	// head stays where it is
+	if m.config.DryRun {
+		log.Info("Dry run reproduced legacy block", "blockNumber", uint64(executionRes.BlockNumber), "hash", executionRes.BlockHash)
+		return ErrDryRunComplete
+	}
+
+	log.Info("Executing block", "blockNumber", uint64(executionRes.BlockNumber))
+
+	// Step 3: Execute payload
+	// engine_newPayload -> Execute payload
+	res, err := m.l2PrivateRpc.ExecutePayload(ctx, executionRes, parentBeaconBlockRoot)
+	var statusErr *engineapi.PayloadStatusError
+	if errors.As(err, &statusErr) && statusErr.IsInvalid() {
+		// The engine rejected the block it built itself or the legacy
+		// block, so let the failure policy decide
+		migrated := string(statusErr.Status)
+		if statusErr.ValidationError != "" {
+			migrated = fmt.Sprintf("%s (%s)", statusErr.Status, statusErr.ValidationError)
 		}
+		return newMismatchError(legacyBlock, fieldPayloadStatus, engineapi.ExecutionValid, migrated, false)
+	}
+	if err != nil {
+		return err
+	}
+	if res.LatestValidHash == nil || *res.LatestValidHash != executionRes.BlockHash {
+		log.Warn("Latest valid hash is not correct", "pending", executionRes.BlockHash, "latest", res.LatestValidHash)
+		return fmt.Errorf("Latest valid hash is not correct")
+	}
 
-		log.Info("Executing block", "blockNumber", uint64(executionRes.BlockNumber))
+	// Step 4: Submit block
+	// engine_executePayloadV1 -> Submit block
+	newfc, err := m.forkchoiceState(ctx, uint64(executionRes.BlockNumber), executionRes.BlockHash)
+	if err != nil {
+		return err
+	}
+	if _, err := m.l2PrivateRpc.ForkchoiceUpdate(ctx, newfc, nil); err != nil {
+		return err
+	}
 
-		// Step 3: Execute payload
-		// engine_newPayloadV1 -> Execute payload
-		res, err := m.l2PrivateRpc.ExecutePayload(executionRes)
-		if err != nil {
+	// verify some information before going on
+	minedBlock, err := m.minedBlock(ctx, uint64(executionRes.BlockNumber), executionRes.BlockHash)
+	if err != nil {
+		log.Warn("Failed to get mined block", "error", err)
+		return err
+	}
+	if minedBlock.Root != legacyBlock.Root || minedBlock.ReceiptHash != legacyBlock.ReceiptHash {
+		logFieldDiffs(compareHeaders(legacyBlock, minedBlock, latestBlock.Hash, version))
+	}
+	if minedBlock.Root != legacyBlock.Root {
+		log.Warn("Block root is not correct", "pending", legacyBlock.Root, "latest", minedBlock.Root)
+		return newMismatchError(legacyBlock, verify.FieldStateRoot, legacyBlock.Root, minedBlock.Root, true)
+	}
+	if minedBlock.ReceiptHash != legacyBlock.ReceiptHash {
+		log.Warn("Receipt hash is not correct", "pending", legacyBlock.ReceiptHash, "latest", minedBlock.ReceiptHash)
+		return newMismatchError(legacyBlock, verify.FieldReceiptsRoot, legacyBlock.ReceiptHash, minedBlock.ReceiptHash, true)
+	}
+	if diffs := compareHeaders(legacyBlock, minedBlock, latestBlock.Hash, version); len(diffs) > 0 {
+		logFieldDiffs(diffs)
+		return newMismatchError(legacyBlock, diffs[0].Field, diffs[0].Legacy, diffs[0].Migrated, true)
+	}
+	if minedBlock.Hash != legacyBlock.Hash {
+		log.Debug("Migrated block has a new hash", "blockNumber", uint64(minedBlock.Number), "legacy", legacyBlock.Hash, "migrated", minedBlock.Hash)
+	}
+	if m.config.VerifyReceipts {
+		if err := m.verifyReceipts(ctx, batch); err != nil {
 			return err
 		}
-		if res.Status != "VALID" {
-			log.Warn("Payload is invalid", "status", res.Status)
-			return fmt.Errorf("payload is invalid")
-
+	}
+	if m.config.VerifyState {
+		if err := m.verifyState(ctx, batch); err != nil {
+			return err
+		}
+	}
+	if m.config.VerifyMetadata {
+		if err := m.verifyMetadata(ctx, batch); err != nil {
+			return err
 		}
-		if *res.LatestValidHash != executionRes.BlockHash {
-			log.Warn("Latest valid hash is not correct", "pending", executionRes.BlockHash, "latest", res.LatestValidHash)
-			return fmt.Errorf("Latest valid hash is not correct")
+	}
+	// The metadata comparison already covers the Turing payload
+	if m.config.VerifyTuring && !m.config.VerifyMetadata {
+		if err := m.verifyTuring(ctx, batch); err != nil {
+			return err
 		}
+	}
+
+	// Only a verified block becomes a checkpoint. A crash before this point
+	// leaves the head one block ahead of the journal, which is verified
+	// again on restart.
+	if err := m.journal.Append(&journal.Entry{
+		Number:     uint64(executionRes.BlockNumber),
+		LegacyHash: legacyBlock.Hash,
+		NewHash:    executionRes.BlockHash,
+		StateRoot:  executionRes.StateRoot,
+		Timestamp:  uint64(executionRes.Timestamp),
+		QueueIndex: batch.queueIndex,
+	}); err != nil {
+		return err
+	}
+
+	m.queue.commit(batch.queueIndex)
+	m.countQueueOrigins(batch.legacyTransactions)
+	m.recordMinedBlock(minedBlock)
+	log.Info("Block mined", "blockNumber", uint64(executionRes.BlockNumber))
+	return nil
+}
 
-		// Step 4: Submit block
-		// engine_executePayloadV1 -> Submit block
-		newfc := &engineapi.ForkchoiceState{
-			HeadBlockHash:      executionRes.BlockHash,
-			SafeBlockHash:      executionRes.BlockHash,
-			FinalizedBlockHash: executionRes.BlockHash,
+// compareHeaders compares a migrated block with the legacy block. Blocks
+// from Shanghai on can't keep the legacy hash, so for them the parent is
+// checked against the migrated parent hash and the hash is left out.
+func compareHeaders(legacyBlock *rpc.LegacyBlock, block *rpc.Block, parentHash common.Hash, version engineapi.Version) []verify.FieldDiff {
+	if version >= engineapi.V2 {
+		return verify.CompareForkHeaders(legacyBlock, block, parentHash)
+	}
+	return verify.CompareHeaders(legacyBlock, block)
+}
+
+// minedBlock returns the block just made the engine head from the public
+// endpoint, waiting for the endpoint to catch up with the engine
+func (m *Miner) minedBlock(ctx context.Context, number uint64, hash common.Hash) (*rpc.Block, error) {
+	deadline := time.Now().Add(time.Duration(m.config.MaxWaitingTime) * time.Second)
+	for {
+		block, err := m.l2PublicRpc.GetBlock(ctx, new(big.Int).SetUint64(number))
+		if err == nil && block.Hash == hash {
+			return block, nil
 		}
-		finalRes, err := m.l2PrivateRpc.ForkchoiceUpdate(newfc, nil)
-		if err != nil {
-			return err
+		if time.Now().After(deadline) {
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("public endpoint reports block %d as %s, expected %s", number, block.Hash, hash)
 		}
-		if finalRes.PayloadStatus.Status != "VALID" {
-			log.Warn("Payload is invalid", "status", finalRes.PayloadStatus.Status)
-			return fmt.Errorf("payload is invalid")
+		select {
+		case <-time.After(minedBlockPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
+	}
+}
 
-		// verify some information before going on
-		latestBlock, err = m.l2PublicRpc.GetLatestBlock()
-		if err != nil {
-			log.Warn("Failed to get latest block", "error", err)
+// buildPayload has the engine build the legacy block on top of latestBlock
+// and returns the built payload
+func (m *Miner) buildPayload(ctx context.Context, latestBlock *rpc.Block, legacyBlock *rpc.LegacyBlock, transactions []engineapi.Data, version engineapi.Version, parentBeaconBlockRoot *common.Hash) (*engineapi.ExecutionPayload, error) {
+	gasLimit := legacyBlock.GasLimit
+
+	// Step 1: Get payloadID
+	// engine_forkchoiceUpdated -> Get payloadID
+	fc, err := m.forkchoiceState(ctx, uint64(latestBlock.Number), latestBlock.Hash)
+	if err != nil {
+		return nil, err
+	}
+	attributes := &engineapi.PayloadAttributes{
+		Timestamp:             hexutil.Uint64(legacyBlock.Time),
+		PrevRandao:            [32]byte{},
+		SuggestedFeeRecipient: common.HexToAddress("0x4200000000000000000000000000000000000011"),
+		Transactions:          transactions,
+		NoTxPool:              true,
+		GasLimit:              &gasLimit,
+		ParentBeaconBlockRoot: parentBeaconBlockRoot,
+	}
+	// Legacy blocks have no withdrawals, but from V2 on the engine expects
+	// an empty list rather than none
+	if version >= engineapi.V2 {
+		attributes.Withdrawals = &engineapi.Withdrawals{}
+	}
+
+	// engine_forkchoiceUpdated
+	fcUpdateRes, err := m.l2PrivateRpc.ForkchoiceUpdate(ctx, fc, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 2: Get executionPayload
+	// engine_getPayload -> Get executionPayload
+	envelope, err := m.l2PrivateRpc.GetPayload(ctx, fcUpdateRes.PayloadID, uint64(attributes.Timestamp))
+	if err != nil {
+		return nil, err
+	}
+	return envelope.ExecutionPayload, nil
+}
 
+// directPayload builds the payload of a legacy block without asking the
+// engine, which then only has to execute it. Only V1 payloads can carry the
+// legacy block hash, so direct mode is refused for blocks from Shanghai on.
+func directPayload(legacyBlock *rpc.LegacyBlock, transactions []engineapi.Data) *engineapi.ExecutionPayload {
+	return engineapi.PayloadFromLegacyBlock(legacyBlock, transactions)
+}
+
+// finalizeHardFork marks the hard fork block safe and finalized. The head
+// is only moved to it if the engine is not already past it, as on a rerun
+// after the handoff.
+func (m *Miner) finalizeHardFork(ctx context.Context, latestBlock *rpc.Block) error {
+	hardForkBlock := latestBlock
+	if uint64(latestBlock.Number) != uint64(m.config.BobaHardForkBlock) {
+		block, err := m.l2PublicRpc.GetBlock(ctx, new(big.Int).SetUint64(uint64(m.config.BobaHardForkBlock)))
+		if err != nil {
+			return fmt.Errorf("failed to get hard fork block: %w", err)
 		}
-		if latestBlock.Root != legacyBlock.Root {
-			log.Warn("Block root is not correct", "pending", legacyBlock.Root, "latest", latestBlock.Root)
-			return fmt.Errorf("Block root is not correct")
+		hardForkBlock = block
+	}
+	fc := &engineapi.ForkchoiceState{
+		HeadBlockHash:      latestBlock.Hash,
+		SafeBlockHash:      hardForkBlock.Hash,
+		FinalizedBlockHash: hardForkBlock.Hash,
+	}
+	if _, err := m.l2PrivateRpc.ForkchoiceUpdate(ctx, fc, nil); err != nil {
+		log.Warn("Failed to finalize hard fork block", "message", err)
+		return fmt.Errorf("failed to finalize hard fork block: %w", err)
+	}
+	m.recordHead(hardForkBlock)
+	m.recordFinalized(hardForkBlock)
+	log.Info("Finalized hard fork block", "blockNumber", uint64(hardForkBlock.Number), "hash", hardForkBlock.Hash, "head", uint64(latestBlock.Number))
+	return nil
+}
+
+// verifyPayloadRoots compares the roots of a built but uncommitted payload
+// with the legacy block
+func verifyPayloadRoots(executionRes *engineapi.ExecutionPayload, legacyBlock *rpc.LegacyBlock) error {
+	if executionRes.StateRoot != legacyBlock.Root {
+		log.Warn("Pending state root is not correct", "pending", executionRes.StateRoot, "legacy", legacyBlock.Root)
+		return newMismatchError(legacyBlock, verify.FieldStateRoot, legacyBlock.Root, executionRes.StateRoot, false)
+	}
+	if executionRes.ReceiptsRoot != legacyBlock.ReceiptHash {
+		log.Warn("Pending receipts root is not correct", "pending", executionRes.ReceiptsRoot, "legacy", legacyBlock.ReceiptHash)
+		return newMismatchError(legacyBlock, verify.FieldReceiptsRoot, legacyBlock.ReceiptHash, executionRes.ReceiptsRoot, false)
+	}
+	return nil
+}
+
+// verifyPayloadTransactions checks that the payload contains exactly the legacy
+// transactions, in the same order
+func verifyPayloadTransactions(executionRes *engineapi.ExecutionPayload, legacyBlock *rpc.LegacyBlock, legacyTransactions []*rpc.LegacyTransaction) error {
+	if len(executionRes.Transactions) != len(legacyTransactions) {
+		log.Warn("Pending transaction length is not correct", "pending", len(executionRes.Transactions), "legacy", len(legacyTransactions))
+		return newMismatchError(legacyBlock, "transaction count", len(legacyTransactions), len(executionRes.Transactions), false)
+	}
+	for i, legacyTransaction := range legacyTransactions {
+		var txType types.Transaction
+		if err := txType.UnmarshalBinary(executionRes.Transactions[i]); err != nil {
+			return fmt.Errorf("failed to unmarshal transaction %d: %w", i, err)
 		}
-		if latestBlock.ReceiptHash != legacyBlock.ReceiptHash {
-			log.Warn("Receipt hash is not correct", "pending", legacyBlock.ReceiptHash, "latest", latestBlock.ReceiptHash)
-			return fmt.Errorf("Receipt hash is not correct")
+		if txType.Hash() != legacyTransaction.Hash() {
+			log.Warn("Pending transaction hash is not correct", "index", i, "pending", txType.Hash(), "legacy", legacyTransaction.Hash())
+			return newMismatchError(legacyBlock, fmt.Sprintf("transaction %d hash", i), legacyTransaction.Hash(), txType.Hash(), false)
 		}
+	}
+	return nil
+}
+
+// legacySeal returns the header fields of a legacy block that its execution
+// payload doesn't carry
+func legacySeal(legacyBlock *rpc.LegacyBlock) *engineapi.Seal {
+	return &engineapi.Seal{
+		UncleHash:  legacyBlock.UncleHash,
+		Difficulty: legacyBlock.Difficulty.ToInt(),
+		Nonce:      legacyBlock.Nonce,
+	}
+}
+
+func logFieldDiffs(diffs []verify.FieldDiff) {
+	for _, diff := range diffs {
+		log.Warn("Header field is not correct", "field", diff.Field, "legacy", diff.Legacy, "migrated", diff.Migrated)
+	}
+}
+
+// writeDiagnostics stores a diagnostic bundle for a diverging block
+func (m *Miner) writeDiagnostics(ctx context.Context, batch *legacyBatch, mismatch *MismatchError) {
+	txHashes := make([]common.Hash, len(batch.legacyTransactions))
+	for i, legacyTransaction := range batch.legacyTransactions {
+		txHashes[i] = legacyTransaction.Hash()
+	}
+	bundle := diagnostic.Collect(ctx, m.l2LegacyRpc, m.l2PublicRpc, batch.number, txHashes, mismatch.Error())
+	path, err := bundle.Write(m.config.DiagnosticDir)
+	if err != nil {
+		log.Error("Failed to write diagnostic bundle", "blockNumber", batch.number, "message", err)
+		return
+	}
+	log.Warn("Wrote diagnostic bundle", "blockNumber", batch.number, "path", path)
+}
+
+// nextLegacyBatch returns the legacy block with the given number from the
+// prefetcher, restarting the prefetcher if it is not positioned at that block
+func (m *Miner) nextLegacyBatch(ctx context.Context, number uint64) (*legacyBatch, error) {
+	if m.prefetcher == nil || m.prefetcher.next != number {
+		m.stopPrefetcher()
+		log.Info("Starting legacy block prefetcher", "start", number, "window", m.config.PrefetchWindow)
+		m.prefetcher = newPrefetcher(ctx, m.l2LegacyRpc, number, m.lastBlock(), m.config.PrefetchWindow, m.config.VerifyReceipts || m.config.VerifyState)
+	}
+	batch, err := m.prefetcher.Next(ctx)
+	if err != nil {
+		m.stopPrefetcher()
+		return nil, err
+	}
+	return batch, nil
+}
 
-		log.Info("Block mined", "blockNumber", uint64(executionRes.BlockNumber))
-		time.Sleep(1 * time.Second)
-		log.Info("Waiting for next block to be mined", "blockNumber", uint64(executionRes.BlockNumber+1))
-		return nil
+func (m *Miner) stopPrefetcher() {
+	if m.prefetcher != nil {
+		m.prefetcher.Close()
+		m.prefetcher = nil
 	}
 }
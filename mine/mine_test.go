@@ -0,0 +1,85 @@
+package mine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/Boyuan-Chen/v3-migration/config"
+	"github.com/Boyuan-Chen/v3-migration/engineapi"
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/Boyuan-Chen/v3-migration/verify"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestRootDivergentPayloadWritesDiagnostics(t *testing.T) {
+	cfg := &config.Config{
+		MaxWaitingTime:       5,
+		PayloadStatusTimeout: 5,
+		FinalizedDepth:       1,
+		MineMode:             MineModeBuild,
+		DryRun:               true,
+		DiagnosticDir:        t.TempDir(),
+	}
+	latestBlock := &rpc.Block{Number: 9, Hash: common.HexToHash("0x09")}
+	legacyBlock := &rpc.LegacyBlock{
+		ParentHash:  latestBlock.Hash,
+		Root:        common.HexToHash("0xaa"),
+		ReceiptHash: common.HexToHash("0xbb"),
+		Bloom:       make(hexutil.Bytes, types.BloomByteLength),
+		Number:      10,
+		Time:        1650000000,
+		Hash:        common.HexToHash("0x10"),
+	}
+
+	// The engine builds the block with a different state root, and so a
+	// different block hash
+	built := engineapi.PayloadFromLegacyBlock(legacyBlock, nil)
+	built.StateRoot = common.HexToHash("0xcc")
+	built.BlockHash = common.HexToHash("0x11")
+	payloadID := engineapi.PayloadID{1}
+	engine := newFakeRpcClient(func(ctx context.Context, method string, args []interface{}) (interface{}, error) {
+		switch method {
+		case "engine_forkchoiceUpdatedV1":
+			return &engineapi.ForkchoiceUpdatedResult{
+				PayloadStatus: engineapi.PayloadStatusV1{Status: engineapi.ExecutionValid, LatestValidHash: &latestBlock.Hash},
+				PayloadID:     &payloadID,
+			}, nil
+		case "engine_getPayloadV1":
+			return built, nil
+		}
+		return nil, errUnexpectedCall(method)
+	})
+	// Both chains answer block queries, so the bundle has something to hold
+	node := func(ctx context.Context, method string, args []interface{}) (interface{}, error) {
+		if method == "eth_getBlockByNumber" {
+			return &rpc.Block{Number: 8, Hash: common.HexToHash("0x08")}, nil
+		}
+		return nil, errUnexpectedCall(method)
+	}
+	m := NewMiner(newFakeRpcClient(node), newFakeRpcClient(node), &engineapi.EngineAPI{Engine: engine.Client, Config: cfg}, nil, cfg)
+	batch := &legacyBatch{number: 10, legacyBlock: legacyBlock}
+
+	err := m.mineLegacyBlock(context.Background(), latestBlock, batch)
+	var mismatch *MismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("mineLegacyBlock = %v, want a MismatchError", err)
+	}
+	if mismatch.Field != verify.FieldStateRoot || mismatch.Committed {
+		t.Fatalf("mismatch = %+v, want an uncommitted %s mismatch", mismatch, verify.FieldStateRoot)
+	}
+
+	if err := m.handleMineError(context.Background(), latestBlock, batch, err); !errors.As(err, &mismatch) {
+		t.Fatalf("handleMineError = %v, want the mismatch", err)
+	}
+	entries, err := os.ReadDir(cfg.DiagnosticDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("diagnostic directory holds %d files, want one bundle", len(entries))
+	}
+}
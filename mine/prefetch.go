@@ -0,0 +1,149 @@
+package mine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	errPrefetchExhausted = errors.New("no more legacy blocks to prefetch")
+	errPrefetcherClosed  = errors.New("legacy block prefetcher closed")
+)
+
+// legacyBatch is a legacy block together with the transaction data the
+// engine stage needs to rebuild it.
+type legacyBatch struct {
+	number             uint64
+	legacyBlock        *rpc.LegacyBlock
+	legacyTransactions []*rpc.LegacyTransaction
+	legacyReceipts     []*rpc.LegacyReceipt
+	queueIndex         *uint64
+	err                error
+}
+
+// prefetcher fetches legacy blocks ahead of the engine stage. Each block is
+// fetched in its own goroutine, but results are handed out strictly in block
+// order. At most window blocks are queued ahead of the consumer, so a slow
+// engine applies backpressure to the legacy endpoint.
+type prefetcher struct {
+	l2LegacyRpc  *rpc.RpcClient
+	withReceipts bool
+	next         uint64
+	queue        chan chan *legacyBatch
+	done         <-chan struct{}
+	cancel       context.CancelFunc
+}
+
+func newPrefetcher(ctx context.Context, l2LegacyRpc *rpc.RpcClient, start uint64, last uint64, window int, withReceipts bool) *prefetcher {
+	if window < 1 {
+		window = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p := &prefetcher{
+		l2LegacyRpc:  l2LegacyRpc,
+		withReceipts: withReceipts,
+		next:         start,
+		queue:        make(chan chan *legacyBatch, window),
+		done:         ctx.Done(),
+		cancel:       cancel,
+	}
+	go p.loop(ctx, start, last)
+	return p
+}
+
+func (p *prefetcher) loop(ctx context.Context, start uint64, last uint64) {
+	defer close(p.queue)
+	for number := start; number <= last; number++ {
+		result := make(chan *legacyBatch, 1)
+		select {
+		case p.queue <- result:
+		case <-ctx.Done():
+			return
+		}
+		go func(number uint64) {
+			result <- p.fetch(ctx, number)
+		}(number)
+	}
+}
+
+func (p *prefetcher) fetch(ctx context.Context, number uint64) *legacyBatch {
+	batch := &legacyBatch{number: number}
+	legacyBlock, err := p.l2LegacyRpc.GetLegacyBlock(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		batch.err = err
+		return batch
+	}
+	if legacyBlock == nil {
+		batch.err = fmt.Errorf("legacy block %d not found", number)
+		return batch
+	}
+	// The block already carries every transaction with its legacy metadata
+	legacyTransactions := legacyBlock.Transactions
+	for i, legacyTransaction := range legacyTransactions {
+		if legacyTransaction == nil {
+			batch.err = fmt.Errorf("legacy block %d has no transaction at index %d", number, i)
+			return batch
+		}
+	}
+	if p.withReceipts {
+		legacyReceipts := make([]*rpc.LegacyReceipt, len(legacyTransactions))
+		for i, legacyTransaction := range legacyTransactions {
+			legacyReceipt, err := p.l2LegacyRpc.GetLegacyReceipt(ctx, legacyTransaction.Hash())
+			if err != nil {
+				batch.err = err
+				return batch
+			}
+			legacyReceipts[i] = legacyReceipt
+		}
+		batch.legacyReceipts = legacyReceipts
+	}
+	batch.legacyBlock = legacyBlock
+	batch.legacyTransactions = legacyTransactions
+	return batch
+}
+
+// Next returns the next legacy block in sequence, waiting for it to be
+// fetched. It returns errPrefetcherClosed once Close is called.
+func (p *prefetcher) Next(ctx context.Context) (*legacyBatch, error) {
+	var result chan *legacyBatch
+	select {
+	case r, ok := <-p.queue:
+		if !ok {
+			select {
+			case <-p.done:
+				return nil, errPrefetcherClosed
+			default:
+				return nil, errPrefetchExhausted
+			}
+		}
+		result = r
+	case <-p.done:
+		return nil, errPrefetcherClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case batch := <-result:
+		if batch.err != nil {
+			return nil, fmt.Errorf("failed to prefetch legacy block %d: %w", batch.number, batch.err)
+		}
+		p.next = batch.number + 1
+		return batch, nil
+	case <-p.done:
+		return nil, errPrefetcherClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops fetching new blocks and unblocks a pending Next. In-flight
+// requests are cancelled and finish in the background.
+func (p *prefetcher) Close() {
+	p.cancel()
+	log.Debug("Stopped legacy block prefetcher", "next", p.next)
+}
@@ -0,0 +1,146 @@
+package mine
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// legacyNode answers eth_getBlockByNumber with an empty legacy block after
+// delay, recording every block it was asked for
+type legacyNode struct {
+	delay func(number uint64) time.Duration
+
+	mu        sync.Mutex
+	requested map[uint64]bool
+}
+
+func (n *legacyNode) client() *rpc.RpcClient {
+	return newFakeRpcClient(func(ctx context.Context, method string, args []interface{}) (interface{}, error) {
+		if method != "eth_getBlockByNumber" {
+			return nil, errUnexpectedCall(method)
+		}
+		number, err := hexutil.DecodeUint64(args[0].(string))
+		if err != nil {
+			return nil, err
+		}
+		n.mu.Lock()
+		if n.requested == nil {
+			n.requested = make(map[uint64]bool)
+		}
+		n.requested[number] = true
+		n.mu.Unlock()
+		if n.delay != nil {
+			select {
+			case <-time.After(n.delay(number)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return &rpc.LegacyBlock{Number: hexutil.Uint64(number)}, nil
+	})
+}
+
+func (n *legacyNode) requestCount() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.requested)
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPrefetcherDeliversInOrder(t *testing.T) {
+	const first, last = 1, 20
+	// Later blocks tend to arrive first
+	latencies := rand.New(rand.NewSource(1)).Perm(last + 1)
+	node := &legacyNode{delay: func(number uint64) time.Duration {
+		return time.Duration(latencies[number]) * time.Millisecond
+	}}
+	p := newPrefetcher(context.Background(), node.client(), first, last, 5, false)
+	defer p.Close()
+
+	for want := uint64(first); want <= last; want++ {
+		batch, err := p.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if batch.number != want || uint64(batch.legacyBlock.Number) != want {
+			t.Fatalf("Next returned block %d, want %d", batch.number, want)
+		}
+		if p.next != want+1 {
+			t.Fatalf("next = %d after block %d", p.next, want)
+		}
+	}
+	if _, err := p.Next(context.Background()); !errors.Is(err, errPrefetchExhausted) {
+		t.Fatalf("Next after the last block = %v, want errPrefetchExhausted", err)
+	}
+}
+
+func TestPrefetcherWindowBoundsFetches(t *testing.T) {
+	const window = 3
+	node := &legacyNode{}
+	p := newPrefetcher(context.Background(), node.client(), 1, 100, window, false)
+	defer p.Close()
+
+	waitFor(t, "the window to fill", func() bool { return node.requestCount() == window })
+	time.Sleep(50 * time.Millisecond)
+	if n := node.requestCount(); n != window {
+		t.Fatalf("%d blocks fetched without a consumer, want %d", n, window)
+	}
+
+	// Taking a block makes room for exactly one more
+	if _, err := p.Next(context.Background()); err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	waitFor(t, "the next fetch", func() bool { return node.requestCount() == window+1 })
+	time.Sleep(50 * time.Millisecond)
+	if n := node.requestCount(); n != window+1 {
+		t.Fatalf("%d blocks fetched after one Next, want %d", n, window+1)
+	}
+}
+
+func TestPrefetcherCloseUnblocksNext(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	// The first block never arrives on its own
+	node := &legacyNode{delay: func(number uint64) time.Duration { return time.Hour }}
+	p := newPrefetcher(context.Background(), node.client(), 1, 100, 4, false)
+	waitFor(t, "the first fetch", func() bool { return node.requestCount() > 0 })
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := p.Next(context.Background())
+		errs <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	p.Close()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errPrefetcherClosed) {
+			t.Fatalf("Next after Close = %v, want errPrefetcherClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next is still blocked after Close")
+	}
+
+	// The loop and every in-flight fetch must exit
+	waitFor(t, "prefetcher goroutines to exit", func() bool { return runtime.NumGoroutine() <= baseline })
+}
@@ -0,0 +1,70 @@
+package mine
+
+import (
+	"fmt"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// queueTracker enforces that L1-to-L2 transactions are migrated in queue
+// order, without gaps
+type queueTracker struct {
+	// lastIndex is the queue index of the last enqueued transaction in a
+	// verified block, nil until the first one is seen
+	lastIndex *uint64
+}
+
+// next checks the queue indexes of a block's legacy transactions and returns
+// the last queue index after the block. The tracker only moves on commit.
+func (q *queueTracker) next(number uint64, txs []*rpc.LegacyTransaction) (*uint64, error) {
+	lastIndex := q.lastIndex
+	for i, tx := range txs {
+		if tx.QueueOrigin != rpc.QueueOriginL1ToL2 {
+			continue
+		}
+		if tx.QueueIndex == nil {
+			return nil, fmt.Errorf("enqueued transaction %d in block %d has no queue index", i, number)
+		}
+		queueIndex := uint64(*tx.QueueIndex)
+		if lastIndex == nil {
+			log.Info("Tracking queue index from first enqueued transaction", "blockNumber", number, "queueIndex", queueIndex)
+		} else if queueIndex != *lastIndex+1 {
+			return nil, fmt.Errorf("enqueued transaction %d in block %d has queue index %d, expected %d", i, number, queueIndex, *lastIndex+1)
+		}
+		lastIndex = &queueIndex
+	}
+	return lastIndex, nil
+}
+
+func (q *queueTracker) commit(lastIndex *uint64) {
+	q.lastIndex = lastIndex
+}
+
+// countQueueOrigins adds the transactions of a migrated block to the per
+// origin counts of the summary, and counts those carrying a Turing payload
+func (m *Miner) countQueueOrigins(txs []*rpc.LegacyTransaction) {
+	for _, tx := range txs {
+		switch tx.QueueOrigin {
+		case rpc.QueueOriginL1ToL2:
+			m.summary.EnqueuedTransactions++
+		default:
+			m.summary.SequencerTransactions++
+		}
+		if tx.HasTuring() {
+			m.summary.TuringTransactions++
+		}
+	}
+}
+
+// lastQueueIndex returns the last queue index after txs, starting from prev
+func lastQueueIndex(prev *uint64, txs []*rpc.LegacyTransaction) *uint64 {
+	lastIndex := prev
+	for _, tx := range txs {
+		if tx.QueueOrigin == rpc.QueueOriginL1ToL2 && tx.QueueIndex != nil {
+			queueIndex := uint64(*tx.QueueIndex)
+			lastIndex = &queueIndex
+		}
+	}
+	return lastIndex
+}
@@ -0,0 +1,127 @@
+package mine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func enqueued(queueIndex uint64) *rpc.LegacyTransaction {
+	index := hexutil.Uint64(queueIndex)
+	return &rpc.LegacyTransaction{LegacyTransactionMeta: rpc.LegacyTransactionMeta{
+		QueueOrigin: rpc.QueueOriginL1ToL2,
+		QueueIndex:  &index,
+	}}
+}
+
+func sequenced() *rpc.LegacyTransaction {
+	return &rpc.LegacyTransaction{LegacyTransactionMeta: rpc.LegacyTransactionMeta{
+		QueueOrigin: rpc.QueueOriginSequencer,
+	}}
+}
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+func TestQueueTrackerNext(t *testing.T) {
+	tests := []struct {
+		name      string
+		lastIndex *uint64
+		txs       []*rpc.LegacyTransaction
+		want      *uint64
+		err       string
+	}{
+		{
+			name: "no enqueued transactions",
+			txs:  []*rpc.LegacyTransaction{sequenced()},
+		},
+		{
+			name: "first index",
+			txs:  []*rpc.LegacyTransaction{sequenced(), enqueued(7)},
+			want: uint64Ptr(7),
+		},
+		{
+			name:      "consecutive indexes",
+			lastIndex: uint64Ptr(7),
+			txs:       []*rpc.LegacyTransaction{enqueued(8), sequenced(), enqueued(9)},
+			want:      uint64Ptr(9),
+		},
+		{
+			name:      "sequencer transactions keep the index",
+			lastIndex: uint64Ptr(7),
+			txs:       []*rpc.LegacyTransaction{sequenced()},
+			want:      uint64Ptr(7),
+		},
+		{
+			name:      "gap",
+			lastIndex: uint64Ptr(7),
+			txs:       []*rpc.LegacyTransaction{enqueued(9)},
+			err:       "has queue index 9, expected 8",
+		},
+		{
+			name: "gap within the block",
+			txs:  []*rpc.LegacyTransaction{enqueued(3), enqueued(5)},
+			err:  "has queue index 5, expected 4",
+		},
+		{
+			name:      "duplicate",
+			lastIndex: uint64Ptr(7),
+			txs:       []*rpc.LegacyTransaction{enqueued(7)},
+			err:       "has queue index 7, expected 8",
+		},
+		{
+			name:      "nil queue index",
+			lastIndex: uint64Ptr(7),
+			txs:       []*rpc.LegacyTransaction{{LegacyTransactionMeta: rpc.LegacyTransactionMeta{QueueOrigin: rpc.QueueOriginL1ToL2}}},
+			err:       "has no queue index",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &queueTracker{lastIndex: tt.lastIndex}
+			got, err := q.next(10, tt.txs)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("next = %v, want error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("next: %v", err)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Fatalf("next = %v, want %v", got, tt.want)
+			}
+			if q.lastIndex != tt.lastIndex {
+				t.Fatalf("next moved the tracker to %v before commit", q.lastIndex)
+			}
+		})
+	}
+}
+
+func TestQueueTrackerNoCommitAfterFailedBlock(t *testing.T) {
+	q := &queueTracker{lastIndex: uint64Ptr(7)}
+
+	// The block passes the queue check but fails verification, so it is
+	// never committed
+	if _, err := q.next(10, []*rpc.LegacyTransaction{enqueued(8)}); err != nil {
+		t.Fatalf("next: %v", err)
+	}
+
+	// Mining the block again must start from the committed index
+	lastIndex, err := q.next(10, []*rpc.LegacyTransaction{enqueued(8)})
+	if err != nil {
+		t.Fatalf("next after a failed block: %v", err)
+	}
+	q.commit(lastIndex)
+	if q.lastIndex == nil || *q.lastIndex != 8 {
+		t.Fatalf("lastIndex after commit = %v, want 8", q.lastIndex)
+	}
+
+	if _, err := q.next(11, []*rpc.LegacyTransaction{enqueued(8)}); err == nil {
+		t.Fatal("next accepted a queue index that was already committed")
+	}
+}
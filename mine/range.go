@@ -0,0 +1,39 @@
+package mine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// lastBlock returns the last block to migrate
+func (m *Miner) lastBlock() uint64 {
+	if m.config.ToBlock > 0 {
+		return uint64(m.config.ToBlock)
+	}
+	return uint64(m.config.BobaHardForkBlock)
+}
+
+// VerifyStartBlock checks that migration can start at the configured first
+// block. Blocks can't be skipped, so the engine head must not be behind it.
+func (m *Miner) VerifyStartBlock(ctx context.Context) error {
+	latestBlock, err := m.l2PublicRpc.GetLatestBlock(ctx)
+	if err != nil {
+		return err
+	}
+	nextBlockNumber := uint64(latestBlock.Number) + 1
+
+	if m.config.FromBlock > 0 {
+		from := uint64(m.config.FromBlock)
+		if nextBlockNumber < from {
+			return fmt.Errorf("engine head %d is behind from block %d, blocks %d to %d would be skipped", uint64(latestBlock.Number), from, nextBlockNumber, from-1)
+		}
+		if nextBlockNumber > from {
+			log.Warn("Engine head is past from block, resuming", "from", from, "next", nextBlockNumber)
+		}
+	}
+
+	log.Info("Migrating block range", "from", nextBlockNumber, "to", m.lastBlock())
+	return nil
+}
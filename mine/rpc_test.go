@@ -0,0 +1,51 @@
+package mine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeRPC is a client.RPC that answers every call with handle. Results are
+// passed through JSON, like a real endpoint's.
+type fakeRPC struct {
+	handle func(ctx context.Context, method string, args []interface{}) (interface{}, error)
+}
+
+func newFakeRpcClient(handle func(ctx context.Context, method string, args []interface{}) (interface{}, error)) *rpc.RpcClient {
+	return &rpc.RpcClient{Client: &fakeRPC{handle: handle}}
+}
+
+func (f *fakeRPC) Close() {}
+
+func (f *fakeRPC) CallContext(ctx context.Context, result any, method string, args ...any) error {
+	res, err := f.handle(ctx, method, args)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+func (f *fakeRPC) BatchCallContext(ctx context.Context, b []gethrpc.BatchElem) error {
+	for i := range b {
+		b[i].Error = f.CallContext(ctx, b[i].Result, b[i].Method, b[i].Args...)
+	}
+	return nil
+}
+
+func (f *fakeRPC) EthSubscribe(ctx context.Context, channel any, args ...any) (ethereum.Subscription, error) {
+	return nil, errors.New("subscriptions are not supported")
+}
+
+func errUnexpectedCall(method string) error {
+	return fmt.Errorf("unexpected call to %s", method)
+}
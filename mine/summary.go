@@ -0,0 +1,47 @@
+package mine
+
+import (
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Summary describes what the miner has migrated so far
+type Summary struct {
+	BlocksMined  uint64
+	LastNumber   uint64
+	LastHash     common.Hash
+	StateRoot    common.Hash
+	ReceiptsRoot common.Hash
+
+	// FinalizedNumber and FinalizedHash are set once the hard fork block is
+	// finalized
+	FinalizedNumber uint64
+	FinalizedHash   common.Hash
+
+	SequencerTransactions uint64
+	EnqueuedTransactions  uint64
+	TuringTransactions    uint64
+}
+
+func (m *Miner) recordMinedBlock(block *rpc.Block) {
+	m.summary.BlocksMined++
+	m.recordHead(block)
+}
+
+func (m *Miner) recordHead(block *rpc.Block) {
+	m.summary.LastNumber = uint64(block.Number)
+	m.summary.LastHash = block.Hash
+	m.summary.StateRoot = block.Root
+	m.summary.ReceiptsRoot = block.ReceiptHash
+}
+
+func (m *Miner) recordFinalized(block *rpc.Block) {
+	m.summary.FinalizedNumber = uint64(block.Number)
+	m.summary.FinalizedHash = block.Hash
+}
+
+// Summary returns the progress of the miner. It must not be called while
+// MineBlock is running.
+func (m *Miner) Summary() Summary {
+	return m.summary
+}
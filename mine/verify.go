@@ -0,0 +1,114 @@
+package mine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/Boyuan-Chen/v3-migration/verify"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// verifyReceipts compares the receipt of every migrated transaction with the
+// prefetched legacy receipt
+func (m *Miner) verifyReceipts(ctx context.Context, batch *legacyBatch) error {
+	for i, legacyReceipt := range batch.legacyReceipts {
+		receipt, err := m.l2PublicRpc.GetReceipt(ctx, legacyReceipt.TxHash)
+		if err != nil {
+			return err
+		}
+		diffs := verify.CompareReceipts(legacyReceipt, receipt)
+		for _, diff := range diffs {
+			log.Warn("Receipt field is not correct", "blockNumber", batch.number, "index", i, "tx", legacyReceipt.TxHash, "field", diff.Field, "legacy", diff.Legacy, "migrated", diff.Migrated)
+		}
+		if len(diffs) > 0 {
+			return newMismatchError(batch.legacyBlock, fmt.Sprintf("receipt %d %s", i, diffs[0].Field), diffs[0].Legacy, diffs[0].Migrated, true)
+		}
+	}
+	return nil
+}
+
+// verifyState compares the accounts touched by the block on the legacy chain
+// and the migrated chain at the block's height. The touched storage slots are
+// traced on the legacy node, which needs the debug namespace.
+func (m *Miner) verifyState(ctx context.Context, batch *legacyBatch) error {
+	receipts := make([]*rpc.Receipt, len(batch.legacyReceipts))
+	prestates := make([]map[common.Address]*rpc.PrestateAccount, len(batch.legacyReceipts))
+	for i, legacyReceipt := range batch.legacyReceipts {
+		receipts[i] = &legacyReceipt.Receipt
+		prestate, err := m.l2LegacyRpc.TracePrestate(ctx, legacyReceipt.TxHash)
+		if err != nil {
+			return err
+		}
+		prestates[i] = prestate
+	}
+	accounts := verify.TouchedAccounts(receipts, prestates)
+	for _, addr := range verify.SortedAddresses(accounts) {
+		legacyAccount, err := verify.GetAccount(ctx, m.l2LegacyRpc, addr, accounts[addr], batch.number)
+		if err != nil {
+			return err
+		}
+		account, err := verify.GetAccount(ctx, m.l2PublicRpc, addr, accounts[addr], batch.number)
+		if err != nil {
+			return err
+		}
+		diffs := verify.CompareAccounts(legacyAccount, account)
+		for _, diff := range diffs {
+			log.Warn("Account state is not correct", "blockNumber", batch.number, "account", addr, "field", diff.Field, "legacy", diff.Legacy, "migrated", diff.Migrated)
+		}
+		if len(diffs) > 0 {
+			return newMismatchError(batch.legacyBlock, fmt.Sprintf("account %s %s", addr, diffs[0].Field), diffs[0].Legacy, diffs[0].Migrated, true)
+		}
+	}
+	log.Debug("Verified touched accounts", "blockNumber", batch.number, "accounts", len(accounts))
+	return nil
+}
+
+// verifyMetadata checks that the migrated chain reports the same legacy
+// metadata for every migrated transaction
+func (m *Miner) verifyMetadata(ctx context.Context, batch *legacyBatch) error {
+	for i, legacyTransaction := range batch.legacyTransactions {
+		migrated, err := m.l2PublicRpc.GetLegacyTransaction(ctx, legacyTransaction.Hash())
+		if err != nil {
+			return err
+		}
+		if migrated == nil {
+			return fmt.Errorf("migrated transaction %s not found", legacyTransaction.Hash())
+		}
+		diffs := verify.CompareTransactionMeta(&legacyTransaction.LegacyTransactionMeta, &migrated.LegacyTransactionMeta)
+		for _, diff := range diffs {
+			log.Warn("Transaction metadata is not correct", "blockNumber", batch.number, "index", i, "tx", legacyTransaction.Hash(), "field", diff.Field, "legacy", diff.Legacy, "migrated", diff.Migrated)
+		}
+		if len(diffs) > 0 {
+			return newMismatchError(batch.legacyBlock, fmt.Sprintf("transaction %d %s", i, diffs[0].Field), diffs[0].Legacy, diffs[0].Migrated, true)
+		}
+	}
+	return nil
+}
+
+// verifyTuring checks that the migrated chain reports the same Turing payload
+// for every migrated transaction that carries one
+func (m *Miner) verifyTuring(ctx context.Context, batch *legacyBatch) error {
+	for i, legacyTransaction := range batch.legacyTransactions {
+		if !legacyTransaction.HasTuring() {
+			continue
+		}
+		migrated, err := m.l2PublicRpc.GetLegacyTransaction(ctx, legacyTransaction.Hash())
+		if err != nil {
+			return err
+		}
+		if migrated == nil {
+			return fmt.Errorf("migrated transaction %s not found", legacyTransaction.Hash())
+		}
+		diffs := verify.CompareTuring(legacyTransaction.L1Turing, migrated.L1Turing)
+		for _, diff := range diffs {
+			log.Warn("Turing payload is not correct", "blockNumber", batch.number, "index", i, "tx", legacyTransaction.Hash(), "field", diff.Field, "legacy", diff.Legacy, "migrated", diff.Migrated)
+		}
+		if len(diffs) > 0 {
+			return newMismatchError(batch.legacyBlock, fmt.Sprintf("transaction %d %s", i, diffs[0].Field), diffs[0].Legacy, diffs[0].Migrated, true)
+		}
+		log.Debug("Verified Turing payload", "blockNumber", batch.number, "index", i, "tx", legacyTransaction.Hash(), "size", len(legacyTransaction.L1Turing))
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum-optimism/optimism/op-node/client"
+	"github.com/ethereum-optimism/optimism/op-node/node"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/log"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
+)
+
+// JWTSecret is a JWT secret that can be reloaded while clients use it
+type JWTSecret struct {
+	load func() (*[32]byte, error)
+
+	mu      sync.RWMutex
+	secret  [32]byte
+	version uint64
+}
+
+// NewJWTSecret loads a secret with load, which is called again on every
+// reload
+func NewJWTSecret(load func() (*[32]byte, error)) (*JWTSecret, error) {
+	secret, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return &JWTSecret{load: load, secret: *secret}, nil
+}
+
+// Get returns the current secret and its version, which changes whenever a
+// reload finds a new secret
+func (s *JWTSecret) Get() ([32]byte, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.secret, s.version
+}
+
+// Reload loads the secret again and reports whether it changed
+func (s *JWTSecret) Reload() (bool, error) {
+	secret, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if *secret == s.secret {
+		return false, nil
+	}
+	s.secret = *secret
+	s.version++
+	log.Info("Reloaded JWT secret", "version", s.version)
+	return true, nil
+}
+
+// IsAuthError reports whether a call was refused because of its JWT
+func IsAuthError(err error) bool {
+	var httpErr gethrpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// NewAuthRpcClient connects to an authenticated endpoint with a secret that
+// may be reloaded. The connection is rebuilt whenever the secret changes, and
+// a call refused with an outdated secret is retried once after a reload.
+func NewAuthRpcClient(ctx context.Context, endpoint string, secret *JWTSecret) (*RpcClient, error) {
+	c := &authClient{ctx: ctx, endpoint: endpoint, secret: secret}
+	if _, _, err := c.current(); err != nil {
+		return nil, err
+	}
+	return &RpcClient{Client: c}, nil
+}
+
+// authClient is a client.RPC whose connection follows a reloadable secret
+type authClient struct {
+	// ctx bounds the lifetime of every connection, like the ctx passed to
+	// NewRpcClient
+	ctx      context.Context
+	endpoint string
+	secret   *JWTSecret
+
+	mu      sync.Mutex
+	client  client.RPC
+	version uint64
+}
+
+// current returns the connection for the current secret and the version of
+// that secret, rebuilding the connection if the secret changed
+func (c *authClient) current() (client.RPC, uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	secret, version := c.secret.Get()
+	if c.client != nil && version == c.version {
+		return c.client, version, nil
+	}
+	l2EndPointConfig := &node.L2EndpointConfig{
+		L2EngineAddr:      c.endpoint,
+		L2EngineJWTSecret: secret,
+	}
+	rpcClient, err := l2EndPointConfig.Setup(c.ctx, log.New("hash"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("Failed to initialize RPC Client: %v", err)
+	}
+	if c.client != nil {
+		log.Info("Rebuilt RPC client with new JWT secret", "endpoint", c.endpoint, "version", version)
+		c.client.Close()
+	}
+	c.client, c.version = rpcClient, version
+	return rpcClient, version, nil
+}
+
+// retry runs call with the current connection and, if the endpoint refused
+// the secret, once more after reloading it
+func (c *authClient) retry(method string, call func(client.RPC) error) error {
+	rpcClient, version, err := c.current()
+	if err != nil {
+		return err
+	}
+	err = call(rpcClient)
+	if !IsAuthError(err) {
+		return err
+	}
+	if _, reloadErr := c.secret.Reload(); reloadErr != nil {
+		log.Warn("Failed to reload JWT secret", "message", reloadErr)
+		return err
+	}
+	// The secret may also have been reloaded by another client since the
+	// call was made
+	if _, latest := c.secret.Get(); latest == version {
+		return err
+	}
+	log.Warn("Call refused with outdated JWT secret, retrying", "endpoint", c.endpoint, "method", method)
+	rpcClient, _, retryErr := c.current()
+	if retryErr != nil {
+		return retryErr
+	}
+	return call(rpcClient)
+}
+
+func (c *authClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil {
+		c.client.Close()
+	}
+}
+
+func (c *authClient) CallContext(ctx context.Context, result any, method string, args ...any) error {
+	return c.retry(method, func(rpcClient client.RPC) error {
+		return rpcClient.CallContext(ctx, result, method, args...)
+	})
+}
+
+func (c *authClient) BatchCallContext(ctx context.Context, b []gethrpc.BatchElem) error {
+	return c.retry("batch", func(rpcClient client.RPC) error {
+		return rpcClient.BatchCallContext(ctx, b)
+	})
+}
+
+func (c *authClient) EthSubscribe(ctx context.Context, channel any, args ...any) (ethereum.Subscription, error) {
+	var sub ethereum.Subscription
+	err := c.retry("eth_subscribe", func(rpcClient client.RPC) error {
+		var err error
+		sub, err = rpcClient.EthSubscribe(ctx, channel, args...)
+		return err
+	})
+	return sub, err
+}
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -13,28 +14,40 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
+	gethrpc "github.com/ethereum/go-ethereum/rpc"
 )
 
 type RpcClient struct {
 	Client client.RPC
 }
 
-func NewRpcClient(endpoint string, secret [32]byte) (*RpcClient, error) {
+func NewRpcClient(ctx context.Context, endpoint string, secret [32]byte) (*RpcClient, error) {
 	l2EndPointConfig := &node.L2EndpointConfig{
 		L2EngineAddr:      endpoint,
 		L2EngineJWTSecret: secret,
 	}
 	logger := log.New("hash")
-	client, err := l2EndPointConfig.Setup(context.Background(), logger)
+	client, err := l2EndPointConfig.Setup(ctx, logger)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to initialize RPC Client: %v", err)
 	}
 	return &RpcClient{Client: client}, nil
 }
 
-func (rpc *RpcClient) GetLatestBlock() (*Block, error) {
+// NewLegacyRpcClient connects to a legacy node, which doesn't authenticate
+// its callers, without a JWT secret
+func NewLegacyRpcClient(ctx context.Context, endpoint string) (*RpcClient, error) {
+	logger := log.New("hash")
+	client, err := client.NewRPC(ctx, logger, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize RPC Client: %v", err)
+	}
+	return &RpcClient{Client: client}, nil
+}
+
+func (rpc *RpcClient) GetLatestBlock(ctx context.Context) (*Block, error) {
 	var block *Block
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 	if err := rpc.Client.CallContext(ctx, &block, "eth_getBlockByNumber", "latest", false); err != nil {
 		return nil, fmt.Errorf("Failed to obtain latest block: %v", err)
@@ -42,9 +55,50 @@ func (rpc *RpcClient) GetLatestBlock() (*Block, error) {
 	return block, nil
 }
 
-func (rpc *RpcClient) GetNextNonce(account *common.Address) (uint64, error) {
+func (rpc *RpcClient) GetBlock(ctx context.Context, num *big.Int) (*Block, error) {
+	var block *Block
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := rpc.Client.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeBig(num), false); err != nil {
+		return nil, fmt.Errorf("Failed to obtain block: %v", err)
+	}
+	if block == nil {
+		return nil, fmt.Errorf("Block %d not found", num)
+	}
+	return block, nil
+}
+
+// GetBlocksByRange returns count blocks from start, with transaction hashes
+// only, in one batch call
+func (rpc *RpcClient) GetBlocksByRange(ctx context.Context, start uint64, count uint64) ([]*Block, error) {
+	blocks := make([]*Block, count)
+	batch := make([]gethrpc.BatchElem, count)
+	for i := range batch {
+		batch[i] = gethrpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []interface{}{hexutil.Uint64(start + uint64(i)), false},
+			Result: &blocks[i],
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	if err := rpc.Client.BatchCallContext(ctx, batch); err != nil {
+		return nil, fmt.Errorf("Failed to obtain blocks: %v", err)
+	}
+	for i, elem := range batch {
+		if elem.Error != nil {
+			return nil, fmt.Errorf("Failed to obtain block %d: %v", start+uint64(i), elem.Error)
+		}
+		if blocks[i] == nil {
+			return nil, fmt.Errorf("Block %d not found", start+uint64(i))
+		}
+	}
+	return blocks, nil
+}
+
+func (rpc *RpcClient) GetNextNonce(ctx context.Context, account *common.Address) (uint64, error) {
 	var nonce hexutil.Uint64
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 	if err := rpc.Client.CallContext(ctx, &nonce, "eth_getTransactionCount", account, "pending"); err != nil {
 		fmt.Println(err)
@@ -53,9 +107,9 @@ func (rpc *RpcClient) GetNextNonce(account *common.Address) (uint64, error) {
 	return uint64(nonce), nil
 }
 
-func (rpc *RpcClient) GetGasPrice() (*big.Int, error) {
+func (rpc *RpcClient) GetGasPrice(ctx context.Context) (*big.Int, error) {
 	var hex hexutil.Big
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 	if err := rpc.Client.CallContext(ctx, &hex, "eth_gasPrice"); err != nil {
 		return nil, fmt.Errorf("Failed to obtain gas price: %v", err)
@@ -63,8 +117,8 @@ func (rpc *RpcClient) GetGasPrice() (*big.Int, error) {
 	return (*big.Int)(&hex), nil
 }
 
-func (rpc *RpcClient) SendRawTransaction(tx *types.Transaction) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+func (rpc *RpcClient) SendRawTransaction(ctx context.Context, tx *types.Transaction) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 	data, err := tx.MarshalBinary()
 	if err != nil {
@@ -77,9 +131,9 @@ func (rpc *RpcClient) SendRawTransaction(tx *types.Transaction) error {
 	return nil
 }
 
-func (rpc *RpcClient) GetBalance(addr common.Address) (*big.Int, error) {
+func (rpc *RpcClient) GetBalance(ctx context.Context, addr common.Address) (*big.Int, error) {
 	var balance string
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 	if err := rpc.Client.CallContext(ctx, &balance, "eth_getBalance", addr, "latest"); err != nil {
 		return nil, fmt.Errorf("Failed to obtain balance: %v", err)
@@ -91,9 +145,9 @@ func (rpc *RpcClient) GetBalance(addr common.Address) (*big.Int, error) {
 	return balanceInt, nil
 }
 
-func (rpc *RpcClient) GetLegacyBlock(num *big.Int) (*LegacyBlock, error) {
+func (rpc *RpcClient) GetLegacyBlock(ctx context.Context, num *big.Int) (*LegacyBlock, error) {
 	var block *LegacyBlock
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 	if err := rpc.Client.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeBig(num), true); err != nil {
 		return nil, fmt.Errorf("Failed to obtain block: %v", err)
@@ -101,12 +155,124 @@ func (rpc *RpcClient) GetLegacyBlock(num *big.Int) (*LegacyBlock, error) {
 	return block, nil
 }
 
-func (rpc *RpcClient) GetLegacyTransaction(hash common.Hash) (*LegacyTransaction, error) {
+func (rpc *RpcClient) GetLegacyTransaction(ctx context.Context, hash common.Hash) (*LegacyTransaction, error) {
 	var tx *LegacyTransaction
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 	if err := rpc.Client.CallContext(ctx, &tx, "eth_getTransactionByHash", hash); err != nil {
 		return nil, fmt.Errorf("Failed to obtain transaction: %v", err)
 	}
 	return tx, nil
 }
+
+func (rpc *RpcClient) GetRawBlock(ctx context.Context, num *big.Int) (json.RawMessage, error) {
+	var block json.RawMessage
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := rpc.Client.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeBig(num), true); err != nil {
+		return nil, fmt.Errorf("Failed to obtain block: %v", err)
+	}
+	return block, nil
+}
+
+func (rpc *RpcClient) GetRawTransactionReceipt(ctx context.Context, hash common.Hash) (json.RawMessage, error) {
+	var receipt json.RawMessage
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := rpc.Client.CallContext(ctx, &receipt, "eth_getTransactionReceipt", hash); err != nil {
+		return nil, fmt.Errorf("Failed to obtain transaction receipt: %v", err)
+	}
+	return receipt, nil
+}
+
+// TraceTransaction returns the call trace of a transaction. Not every
+// endpoint exposes the debug namespace.
+func (rpc *RpcClient) TraceTransaction(ctx context.Context, hash common.Hash) (json.RawMessage, error) {
+	var trace json.RawMessage
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	config := map[string]interface{}{"tracer": "callTracer"}
+	if err := rpc.Client.CallContext(ctx, &trace, "debug_traceTransaction", hash, config); err != nil {
+		return nil, fmt.Errorf("Failed to trace transaction: %v", err)
+	}
+	return trace, nil
+}
+
+// TracePrestate returns the accounts and storage slots a transaction accessed,
+// using the prestateTracer. Not every endpoint exposes the debug namespace.
+func (rpc *RpcClient) TracePrestate(ctx context.Context, hash common.Hash) (map[common.Address]*PrestateAccount, error) {
+	var prestate map[common.Address]*PrestateAccount
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	config := map[string]interface{}{"tracer": "prestateTracer"}
+	if err := rpc.Client.CallContext(ctx, &prestate, "debug_traceTransaction", hash, config); err != nil {
+		return nil, fmt.Errorf("Failed to trace transaction prestate: %v", err)
+	}
+	return prestate, nil
+}
+
+func (rpc *RpcClient) GetLegacyReceipt(ctx context.Context, hash common.Hash) (*LegacyReceipt, error) {
+	var receipt *LegacyReceipt
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := rpc.Client.CallContext(ctx, &receipt, "eth_getTransactionReceipt", hash); err != nil {
+		return nil, fmt.Errorf("Failed to obtain legacy receipt: %v", err)
+	}
+	if receipt == nil {
+		return nil, fmt.Errorf("Legacy receipt %s not found", hash)
+	}
+	return receipt, nil
+}
+
+func (rpc *RpcClient) GetReceipt(ctx context.Context, hash common.Hash) (*Receipt, error) {
+	var receipt *Receipt
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := rpc.Client.CallContext(ctx, &receipt, "eth_getTransactionReceipt", hash); err != nil {
+		return nil, fmt.Errorf("Failed to obtain receipt: %v", err)
+	}
+	if receipt == nil {
+		return nil, fmt.Errorf("Receipt %s not found", hash)
+	}
+	return receipt, nil
+}
+
+func (rpc *RpcClient) GetBalanceAt(ctx context.Context, addr common.Address, num *big.Int) (*big.Int, error) {
+	var balance hexutil.Big
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := rpc.Client.CallContext(ctx, &balance, "eth_getBalance", addr, hexutil.EncodeBig(num)); err != nil {
+		return nil, fmt.Errorf("Failed to obtain balance: %v", err)
+	}
+	return (*big.Int)(&balance), nil
+}
+
+func (rpc *RpcClient) GetNonceAt(ctx context.Context, addr common.Address, num *big.Int) (uint64, error) {
+	var nonce hexutil.Uint64
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := rpc.Client.CallContext(ctx, &nonce, "eth_getTransactionCount", addr, hexutil.EncodeBig(num)); err != nil {
+		return 0, fmt.Errorf("Failed to obtain nonce: %v", err)
+	}
+	return uint64(nonce), nil
+}
+
+func (rpc *RpcClient) GetCodeAt(ctx context.Context, addr common.Address, num *big.Int) ([]byte, error) {
+	var code hexutil.Bytes
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := rpc.Client.CallContext(ctx, &code, "eth_getCode", addr, hexutil.EncodeBig(num)); err != nil {
+		return nil, fmt.Errorf("Failed to obtain code: %v", err)
+	}
+	return code, nil
+}
+
+func (rpc *RpcClient) GetStorageAt(ctx context.Context, addr common.Address, slot common.Hash, num *big.Int) (common.Hash, error) {
+	var value hexutil.Bytes
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	if err := rpc.Client.CallContext(ctx, &value, "eth_getStorageAt", addr, slot, hexutil.EncodeBig(num)); err != nil {
+		return common.Hash{}, fmt.Errorf("Failed to obtain storage: %v", err)
+	}
+	return common.BytesToHash(value), nil
+}
@@ -1,6 +1,9 @@
 package rpc
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -55,9 +58,134 @@ type LegacyBlock struct {
 	// untrusted info included by RPC, may have to be checked
 	Hash common.Hash `json:"hash"`
 
-	Transactions []*types.Transaction `json:"transactions"`
+	Transactions []*LegacyTransaction `json:"transactions"`
 }
 
+// LegacyTransaction is a transaction as returned by a legacy Boba node,
+// including the rollup metadata it attaches
 type LegacyTransaction struct {
 	types.Transaction
+	LegacyTransactionMeta
+}
+
+type LegacyTransactionMeta struct {
+	L1BlockNumber   *hexutil.Big    `json:"l1BlockNumber"`
+	L1Timestamp     hexutil.Uint64  `json:"l1Timestamp"`
+	L1Turing        hexutil.Bytes   `json:"l1Turing"`
+	L1MessageSender *common.Address `json:"l1MessageSender"`
+	QueueOrigin     QueueOrigin     `json:"queueOrigin"`
+	Index           *hexutil.Uint64 `json:"index"`
+	QueueIndex      *hexutil.Uint64 `json:"queueIndex"`
+	RawTransaction  hexutil.Bytes   `json:"rawTransaction"`
+}
+
+// HasTuring reports whether the transaction carries the result of a Turing
+// off-chain call. Legacy nodes report an empty value or a single zero byte
+// for transactions without one.
+func (meta *LegacyTransactionMeta) HasTuring() bool {
+	return len(meta.L1Turing) > 1 || (len(meta.L1Turing) == 1 && meta.L1Turing[0] != 0)
+}
+
+// UnmarshalJSON decodes both the transaction and its metadata. Without it the
+// embedded transaction's decoder would drop the metadata.
+func (tx *LegacyTransaction) UnmarshalJSON(input []byte) error {
+	if err := tx.Transaction.UnmarshalJSON(input); err != nil {
+		return err
+	}
+	var meta struct {
+		LegacyTransactionMeta
+		// Some legacy nodes report the L1 message sender as l1TxOrigin
+		L1TxOrigin *common.Address `json:"l1TxOrigin"`
+	}
+	if err := json.Unmarshal(input, &meta); err != nil {
+		return err
+	}
+	tx.LegacyTransactionMeta = meta.LegacyTransactionMeta
+	if tx.L1MessageSender == nil {
+		tx.L1MessageSender = meta.L1TxOrigin
+	}
+	return nil
+}
+
+// QueueOrigin tells whether a legacy transaction was submitted to the
+// sequencer or enqueued on L1
+type QueueOrigin uint8
+
+const (
+	QueueOriginSequencer QueueOrigin = 0
+	QueueOriginL1ToL2    QueueOrigin = 1
+)
+
+func (q QueueOrigin) String() string {
+	switch q {
+	case QueueOriginSequencer:
+		return "sequencer"
+	case QueueOriginL1ToL2:
+		return "l1"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(q))
+	}
+}
+
+func (q QueueOrigin) MarshalText() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON accepts both the string and the numeric encoding
+func (q *QueueOrigin) UnmarshalJSON(input []byte) error {
+	var name string
+	if err := json.Unmarshal(input, &name); err != nil {
+		var number uint8
+		if err := json.Unmarshal(input, &number); err != nil {
+			return fmt.Errorf("invalid queue origin %s", input)
+		}
+		*q = QueueOrigin(number)
+		return nil
+	}
+	switch name {
+	case "sequencer", "":
+		*q = QueueOriginSequencer
+	case "l1":
+		*q = QueueOriginL1ToL2
+	default:
+		return fmt.Errorf("invalid queue origin %q", name)
+	}
+	return nil
+}
+
+type Receipt struct {
+	Type              hexutil.Uint64  `json:"type"`
+	Status            hexutil.Uint64  `json:"status"`
+	CumulativeGasUsed hexutil.Uint64  `json:"cumulativeGasUsed"`
+	GasUsed           hexutil.Uint64  `json:"gasUsed"`
+	ContractAddress   *common.Address `json:"contractAddress"`
+	Logs              []*Log          `json:"logs"`
+	TxHash            common.Hash     `json:"transactionHash"`
+	TransactionIndex  hexutil.Uint64  `json:"transactionIndex"`
+	BlockHash         common.Hash     `json:"blockHash"`
+	BlockNumber       hexutil.Uint64  `json:"blockNumber"`
+	From              common.Address  `json:"from"`
+	To                *common.Address `json:"to"`
+}
+
+// LegacyReceipt carries the L1 fee fields that legacy Boba receipts report
+type LegacyReceipt struct {
+	Receipt
+	L1GasPrice  *hexutil.Big `json:"l1GasPrice"`
+	L1GasUsed   *hexutil.Big `json:"l1GasUsed"`
+	L1Fee       *hexutil.Big `json:"l1Fee"`
+	L1FeeScalar string       `json:"l1FeeScalar"`
+}
+
+type Log struct {
+	Address  common.Address `json:"address"`
+	Topics   []common.Hash  `json:"topics"`
+	Data     hexutil.Bytes  `json:"data"`
+	LogIndex hexutil.Uint64 `json:"logIndex"`
+}
+
+// PrestateAccount is an account as reported by the prestateTracer. Storage
+// holds every slot the transaction read or wrote.
+type PrestateAccount struct {
+	Storage map[common.Hash]common.Hash `json:"storage"`
 }
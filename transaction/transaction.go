@@ -24,21 +24,9 @@ type TransactionBuilder struct {
 
 type rpcTransaction struct {
 	types.Transaction
-	rpcTransactionMeta
 	txExtraInfo
 }
 
-type rpcTransactionMeta struct {
-	L1BlockNumber   *big.Int        `json:"l1BlockNumber"`
-	L1Timestamp     uint64          `json:"l1Timestamp"`
-	L1Turing        []byte          `json:"l1Turing"`
-	L1MessageSender *common.Address `json:"l1MessageSender"`
-	QueueOrigin     uint8           `json:"queueOrigin"`
-	Index           *uint64         `json:"index"`
-	QueueIndex      *uint64         `json:"queueIndex"`
-	RawTransaction  []byte          `json:"rawTransaction"`
-}
-
 type txExtraInfo struct {
 	BlockNumber *string         `json:"blockNumber,omitempty"`
 	BlockHash   *common.Hash    `json:"blockHash,omitempty"`
@@ -53,15 +41,15 @@ func NewTransactionBuilder(smartContractViewer *SmartContractViewer, rpcClient *
 	}
 }
 
-func (t *TransactionBuilder) BuildTestTransaction(key string) (*types.Transaction, error) {
+func (t *TransactionBuilder) BuildTestTransaction(ctx context.Context, key string) (*types.Transaction, error) {
 	ecskey, _ := crypto.HexToECDSA(key)
 	address := crypto.PubkeyToAddress(ecskey.PublicKey)
 	signer := types.NewEIP155Signer(t.RollupConfig.L2ChainID)
-	nonce, err := t.RpcClient.GetNextNonce(&address)
+	nonce, err := t.RpcClient.GetNextNonce(ctx, &address)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get nonce: %s", err.Error())
 	}
-	gasPrice, err := t.RpcClient.GetGasPrice()
+	gasPrice, err := t.RpcClient.GetGasPrice(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get gas price: %s", err.Error())
 	}
@@ -160,13 +148,13 @@ func (t *TransactionBuilder) GetPastTransaction() (*types.Transaction, error) {
 // 	l1InfoTx, err := derive.L1InfoDepositBytes(seqNum, l1Info, sysConfig)
 // }
 
-func (t *TransactionBuilder) SubmitTransaction(key string) error {
+func (t *TransactionBuilder) SubmitTransaction(ctx context.Context, key string) error {
 	fmt.Println("Building and Submitting Test Transaction...")
-	tx, err := t.BuildTestTransaction(key)
+	tx, err := t.BuildTestTransaction(ctx, key)
 	if err != nil {
 		return fmt.Errorf("Failed to build transaction: %s", err.Error())
 	}
-	err = t.RpcClient.SendRawTransaction(tx)
+	err = t.RpcClient.SendRawTransaction(ctx, tx)
 	if err != nil {
 		return fmt.Errorf("Failed to send transaction: %s", err.Error())
 	}
@@ -0,0 +1,65 @@
+package turing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Record is a legacy transaction carrying a Turing payload, as written by Dump
+type Record struct {
+	BlockNumber uint64          `json:"blockNumber"`
+	Index       int             `json:"index"`
+	Hash        common.Hash     `json:"hash"`
+	QueueOrigin rpc.QueueOrigin `json:"queueOrigin"`
+	To          *common.Address `json:"to"`
+	L1Turing    hexutil.Bytes   `json:"l1Turing"`
+}
+
+// Dump writes every transaction carrying a Turing payload in the blocks from
+// and to, both inclusive, as one JSON record per line. It returns the number
+// of records written.
+func Dump(ctx context.Context, rpcClient *rpc.RpcClient, from uint64, to uint64, w io.Writer) (int, error) {
+	encoder := json.NewEncoder(w)
+	count := 0
+	for number := from; number <= to; number++ {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		block, err := rpcClient.GetLegacyBlock(ctx, new(big.Int).SetUint64(number))
+		if err != nil {
+			return count, err
+		}
+		if block == nil {
+			return count, fmt.Errorf("legacy block %d not found", number)
+		}
+		for i, tx := range block.Transactions {
+			if !tx.HasTuring() {
+				continue
+			}
+			record := &Record{
+				BlockNumber: number,
+				Index:       i,
+				Hash:        tx.Hash(),
+				QueueOrigin: tx.QueueOrigin,
+				To:          tx.To(),
+				L1Turing:    tx.L1Turing,
+			}
+			if err := encoder.Encode(record); err != nil {
+				return count, fmt.Errorf("Failed to write Turing record: %v", err)
+			}
+			count++
+		}
+		if number%1000 == 0 {
+			log.Info("Scanning legacy blocks for Turing payloads", "blockNumber", number, "found", count)
+		}
+	}
+	return count, nil
+}
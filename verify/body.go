@@ -0,0 +1,113 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Boyuan-Chen/v3-migration/engineapi"
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// CompareBody compares the transactions of a migrated block body with the
+// transaction hashes of the legacy block. Transactions are compared by hash,
+// in order, and only the first differing one is reported.
+func CompareBody(legacy *rpc.Block, body *engineapi.ExecutionPayloadBody) []FieldDiff {
+	d := &differ{}
+	if body == nil {
+		d.check("body", false, len(legacy.Transactions), nil)
+		return d.diffs
+	}
+	d.check("transactions.length", len(legacy.Transactions) == len(body.Transactions), len(legacy.Transactions), len(body.Transactions))
+	for i := 0; i < len(legacy.Transactions) && i < len(body.Transactions); i++ {
+		hash := crypto.Keccak256Hash(body.Transactions[i])
+		if legacy.Transactions[i] == nil || *legacy.Transactions[i] != hash {
+			d.diffs = append(d.diffs, FieldDiff{Field: fmt.Sprintf("transactions[%d]", i), Legacy: legacy.Transactions[i], Migrated: hash})
+			break
+		}
+	}
+	return d.diffs
+}
+
+// SweepBodies compares the transactions of every block from from to to, both
+// inclusive, on the migrated chain with the legacy chain. Blocks are fetched
+// from both chains in batches of batchSize, with workers batches in flight.
+// It returns the number of blocks that differ.
+func SweepBodies(ctx context.Context, legacyRpc *rpc.RpcClient, engine *engineapi.EngineAPI, from uint64, to uint64, batchSize uint64, workers int) (int, error) {
+	if batchSize == 0 || batchSize > engineapi.MaxPayloadBodiesRange || workers <= 0 {
+		return 0, fmt.Errorf("invalid batch size %d or worker count %d", batchSize, workers)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	starts := make(chan uint64)
+	go func() {
+		defer close(starts)
+		for start := from; start <= to; start += batchSize {
+			select {
+			case starts <- start:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		mismatched int
+		firstErr   error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range starts {
+				count := batchSize
+				if to-start+1 < count {
+					count = to - start + 1
+				}
+				n, err := sweepBatch(ctx, legacyRpc, engine, start, count)
+				mu.Lock()
+				mismatched += n
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return mismatched, firstErr
+}
+
+func sweepBatch(ctx context.Context, legacyRpc *rpc.RpcClient, engine *engineapi.EngineAPI, start uint64, count uint64) (int, error) {
+	bodies, err := engine.GetPayloadBodiesByRange(ctx, start, count)
+	if err != nil {
+		return 0, err
+	}
+	legacyBlocks, err := legacyRpc.GetBlocksByRange(ctx, start, count)
+	if err != nil {
+		return 0, err
+	}
+	mismatched := 0
+	for i, legacyBlock := range legacyBlocks {
+		number := start + uint64(i)
+		var body *engineapi.ExecutionPayloadBody
+		if i < len(bodies) {
+			body = bodies[i]
+		}
+		diffs := CompareBody(legacyBlock, body)
+		for _, diff := range diffs {
+			log.Warn("Block body is not correct", "blockNumber", number, "field", diff.Field, "legacy", diff.Legacy, "migrated", diff.Migrated)
+		}
+		if len(diffs) > 0 {
+			mismatched++
+		}
+	}
+	log.Info("Verified block bodies", "from", start, "to", start+count-1, "mismatched", mismatched)
+	return mismatched, nil
+}
@@ -0,0 +1,171 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/Boyuan-Chen/v3-migration/engineapi"
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Names of the root fields. The miner uses them too, to tell a diverging
+// execution apart from a block that was assembled differently.
+const (
+	FieldStateRoot    = "stateRoot"
+	FieldReceiptsRoot = "receiptsRoot"
+)
+
+// FieldDiff is a field whose value differs between the legacy and the
+// migrated chain
+type FieldDiff struct {
+	Field    string
+	Legacy   interface{}
+	Migrated interface{}
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: legacy %v, migrated %v", d.Field, d.Legacy, d.Migrated)
+}
+
+type differ struct {
+	diffs []FieldDiff
+}
+
+func (d *differ) check(field string, equal bool, legacy interface{}, migrated interface{}) {
+	if !equal {
+		d.diffs = append(d.diffs, FieldDiff{Field: field, Legacy: legacy, Migrated: migrated})
+	}
+}
+
+// equalBig compares optional numbers. A missing value is not the same as zero;
+// for the base fee it even changes the header encoding.
+func equalBig(a *hexutil.Big, b *hexutil.Big) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.ToInt().Cmp(b.ToInt()) == 0
+}
+
+func equalBaseFee(a *hexutil.Big, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.ToInt().Cmp(b) == 0
+}
+
+func equalUint64(a *hexutil.Uint64, b *hexutil.Uint64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func equalAddress(a *common.Address, b *common.Address) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// CompareHeaders compares every header field of a migrated block with the
+// legacy block
+func CompareHeaders(legacy *rpc.LegacyBlock, migrated *rpc.Block) []FieldDiff {
+	d := &differ{}
+	d.check("parentHash", legacy.ParentHash == migrated.ParentHash, legacy.ParentHash, migrated.ParentHash)
+	compareHeaderFields(d, legacy, migrated)
+	d.check("hash", legacy.Hash == migrated.Hash, legacy.Hash, migrated.Hash)
+	return d.diffs
+}
+
+// CompareForkHeaders compares a migrated block from Shanghai on with the
+// legacy block. Its header has fields the legacy header lacks, so its hash
+// and the parent hash of every later block differ from the legacy chain: the
+// parent hash is checked against the migrated parent and the hash is not
+// compared.
+func CompareForkHeaders(legacy *rpc.LegacyBlock, migrated *rpc.Block, parentHash common.Hash) []FieldDiff {
+	d := &differ{}
+	d.check("parentHash", parentHash == migrated.ParentHash, parentHash, migrated.ParentHash)
+	compareHeaderFields(d, legacy, migrated)
+	return d.diffs
+}
+
+func compareHeaderFields(d *differ, legacy *rpc.LegacyBlock, migrated *rpc.Block) {
+	d.check("sha3Uncles", legacy.UncleHash == migrated.UncleHash, legacy.UncleHash, migrated.UncleHash)
+	d.check("miner", legacy.Coinbase == migrated.Coinbase, legacy.Coinbase, migrated.Coinbase)
+	d.check(FieldStateRoot, legacy.Root == migrated.Root, legacy.Root, migrated.Root)
+	d.check("transactionsRoot", legacy.TxHash == migrated.TxHash, legacy.TxHash, migrated.TxHash)
+	d.check(FieldReceiptsRoot, legacy.ReceiptHash == migrated.ReceiptHash, legacy.ReceiptHash, migrated.ReceiptHash)
+	d.check("logsBloom", bytes.Equal(legacy.Bloom, migrated.Bloom), legacy.Bloom, migrated.Bloom)
+	d.check("difficulty", legacy.Difficulty.ToInt().Cmp(migrated.Difficulty.ToInt()) == 0, &legacy.Difficulty, &migrated.Difficulty)
+	d.check("number", legacy.Number == migrated.Number, legacy.Number, migrated.Number)
+	d.check("gasLimit", legacy.GasLimit == migrated.GasLimit, legacy.GasLimit, migrated.GasLimit)
+	d.check("gasUsed", legacy.GasUsed == migrated.GasUsed, legacy.GasUsed, migrated.GasUsed)
+	d.check("timestamp", legacy.Time == migrated.Time, legacy.Time, migrated.Time)
+	d.check("extraData", bytes.Equal(legacy.Extra, migrated.Extra), legacy.Extra, migrated.Extra)
+	d.check("mixHash", legacy.MixDigest == migrated.MixDigest, legacy.MixDigest, migrated.MixDigest)
+	d.check("nonce", legacy.Nonce == migrated.Nonce, legacy.Nonce, migrated.Nonce)
+	d.check("baseFeePerGas", equalBig(legacy.BaseFee, migrated.BaseFee), legacy.BaseFee, migrated.BaseFee)
+}
+
+// ComparePayload compares the header fields carried by an execution payload
+// with the legacy block. Fields a payload doesn't carry (uncles, transactions
+// root, difficulty and nonce) are not compared.
+func ComparePayload(legacy *rpc.LegacyBlock, payload *engineapi.ExecutionPayload) []FieldDiff {
+	d := &differ{}
+	d.check("parentHash", legacy.ParentHash == payload.ParentHash, legacy.ParentHash, payload.ParentHash)
+	comparePayloadFields(d, legacy, payload)
+	d.check("hash", legacy.Hash == payload.BlockHash, legacy.Hash, payload.BlockHash)
+	return d.diffs
+}
+
+// CompareForkPayload compares a payload from Shanghai on with the legacy
+// block, checking the parent hash against the migrated parent and leaving out
+// the block hash, like CompareForkHeaders
+func CompareForkPayload(legacy *rpc.LegacyBlock, payload *engineapi.ExecutionPayload, parentHash common.Hash) []FieldDiff {
+	d := &differ{}
+	d.check("parentHash", parentHash == payload.ParentHash, parentHash, payload.ParentHash)
+	comparePayloadFields(d, legacy, payload)
+	return d.diffs
+}
+
+func comparePayloadFields(d *differ, legacy *rpc.LegacyBlock, payload *engineapi.ExecutionPayload) {
+	d.check("miner", legacy.Coinbase == payload.FeeRecipient, legacy.Coinbase, payload.FeeRecipient)
+	d.check(FieldStateRoot, legacy.Root == payload.StateRoot, legacy.Root, payload.StateRoot)
+	d.check(FieldReceiptsRoot, legacy.ReceiptHash == payload.ReceiptsRoot, legacy.ReceiptHash, payload.ReceiptsRoot)
+	d.check("logsBloom", bytes.Equal(legacy.Bloom, payload.LogsBloom), legacy.Bloom, payload.LogsBloom)
+	d.check("number", legacy.Number == payload.BlockNumber, legacy.Number, payload.BlockNumber)
+	d.check("gasLimit", legacy.GasLimit == payload.GasLimit, legacy.GasLimit, payload.GasLimit)
+	d.check("gasUsed", legacy.GasUsed == payload.GasUsed, legacy.GasUsed, payload.GasUsed)
+	d.check("timestamp", legacy.Time == payload.Timestamp, legacy.Time, payload.Timestamp)
+	d.check("extraData", bytes.Equal(legacy.Extra, payload.ExtraData), legacy.Extra, payload.ExtraData)
+	d.check("mixHash", legacy.MixDigest == payload.PrevRandao, legacy.MixDigest, payload.PrevRandao)
+	d.check("baseFeePerGas", equalBig(legacy.BaseFee, payload.BaseFeePerGas), legacy.BaseFee, payload.BaseFeePerGas)
+}
+
+// CompareLocalHeader compares a header rebuilt locally from an execution
+// payload with the legacy block, so neither node's block hash is trusted
+func CompareLocalHeader(legacy *rpc.LegacyBlock, header *types.Header) []FieldDiff {
+	d := &differ{}
+	d.check("parentHash", legacy.ParentHash == header.ParentHash, legacy.ParentHash, header.ParentHash)
+	d.check("sha3Uncles", legacy.UncleHash == header.UncleHash, legacy.UncleHash, header.UncleHash)
+	d.check("miner", legacy.Coinbase == header.Coinbase, legacy.Coinbase, header.Coinbase)
+	d.check(FieldStateRoot, legacy.Root == header.Root, legacy.Root, header.Root)
+	d.check("transactionsRoot", legacy.TxHash == header.TxHash, legacy.TxHash, header.TxHash)
+	d.check(FieldReceiptsRoot, legacy.ReceiptHash == header.ReceiptHash, legacy.ReceiptHash, header.ReceiptHash)
+	d.check("logsBloom", bytes.Equal(legacy.Bloom, header.Bloom.Bytes()), legacy.Bloom, header.Bloom.Bytes())
+	d.check("difficulty", legacy.Difficulty.ToInt().Cmp(header.Difficulty) == 0, &legacy.Difficulty, header.Difficulty)
+	d.check("number", uint64(legacy.Number) == header.Number.Uint64(), legacy.Number, header.Number)
+	d.check("gasLimit", uint64(legacy.GasLimit) == header.GasLimit, legacy.GasLimit, header.GasLimit)
+	d.check("gasUsed", uint64(legacy.GasUsed) == header.GasUsed, legacy.GasUsed, header.GasUsed)
+	d.check("timestamp", uint64(legacy.Time) == header.Time, legacy.Time, header.Time)
+	d.check("extraData", bytes.Equal(legacy.Extra, header.Extra), legacy.Extra, header.Extra)
+	d.check("mixHash", legacy.MixDigest == header.MixDigest, legacy.MixDigest, header.MixDigest)
+	d.check("nonce", legacy.Nonce == header.Nonce, legacy.Nonce, header.Nonce)
+	d.check("baseFeePerGas", equalBaseFee(legacy.BaseFee, header.BaseFee), legacy.BaseFee, header.BaseFee)
+	d.check("hash", legacy.Hash == header.Hash(), legacy.Hash, header.Hash())
+	return d.diffs
+}
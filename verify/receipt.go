@@ -0,0 +1,46 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+)
+
+// CompareReceipts compares the receipt of a migrated transaction with the
+// legacy receipt. Logs are compared in order and only the first differing log
+// field is reported, since everything after it is usually a consequence.
+func CompareReceipts(legacy *rpc.LegacyReceipt, migrated *rpc.Receipt) []FieldDiff {
+	d := &differ{}
+	d.check("status", legacy.Status == migrated.Status, legacy.Status, migrated.Status)
+	d.check("cumulativeGasUsed", legacy.CumulativeGasUsed == migrated.CumulativeGasUsed, legacy.CumulativeGasUsed, migrated.CumulativeGasUsed)
+	d.check("contractAddress", equalAddress(legacy.ContractAddress, migrated.ContractAddress), legacy.ContractAddress, migrated.ContractAddress)
+	if diff := compareLogs(legacy.Logs, migrated.Logs); diff != nil {
+		d.diffs = append(d.diffs, *diff)
+	}
+	return d.diffs
+}
+
+func compareLogs(legacy []*rpc.Log, migrated []*rpc.Log) *FieldDiff {
+	for i := 0; i < len(legacy) && i < len(migrated); i++ {
+		field := fmt.Sprintf("logs[%d]", i)
+		if legacy[i].Address != migrated[i].Address {
+			return &FieldDiff{Field: field + ".address", Legacy: legacy[i].Address, Migrated: migrated[i].Address}
+		}
+		if len(legacy[i].Topics) != len(migrated[i].Topics) {
+			return &FieldDiff{Field: field + ".topics.length", Legacy: len(legacy[i].Topics), Migrated: len(migrated[i].Topics)}
+		}
+		for j := range legacy[i].Topics {
+			if legacy[i].Topics[j] != migrated[i].Topics[j] {
+				return &FieldDiff{Field: fmt.Sprintf("%s.topics[%d]", field, j), Legacy: legacy[i].Topics[j], Migrated: migrated[i].Topics[j]}
+			}
+		}
+		if !bytes.Equal(legacy[i].Data, migrated[i].Data) {
+			return &FieldDiff{Field: field + ".data", Legacy: legacy[i].Data, Migrated: migrated[i].Data}
+		}
+	}
+	if len(legacy) != len(migrated) {
+		return &FieldDiff{Field: "logs.length", Legacy: len(legacy), Migrated: len(migrated)}
+	}
+	return nil
+}
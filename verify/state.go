@@ -0,0 +1,138 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Account is the part of an account's state we can read over RPC
+type Account struct {
+	Address  common.Address
+	Balance  *big.Int
+	Nonce    uint64
+	CodeHash common.Hash
+	Storage  map[common.Hash]common.Hash
+}
+
+// TouchedAccounts returns the accounts a block's transactions touched, with
+// the storage slots they accessed. Senders, recipients, created contracts and
+// log emitters come from the receipts; every other account and every storage
+// slot comes from the prestate traces of the transactions.
+func TouchedAccounts(receipts []*rpc.Receipt, prestates []map[common.Address]*rpc.PrestateAccount) map[common.Address][]common.Hash {
+	touched := make(map[common.Address]map[common.Hash]struct{})
+	touch := func(addr common.Address) map[common.Hash]struct{} {
+		if touched[addr] == nil {
+			touched[addr] = make(map[common.Hash]struct{})
+		}
+		return touched[addr]
+	}
+	for _, receipt := range receipts {
+		touch(receipt.From)
+		if receipt.To != nil {
+			touch(*receipt.To)
+		}
+		if receipt.ContractAddress != nil {
+			touch(*receipt.ContractAddress)
+		}
+		for _, log := range receipt.Logs {
+			touch(log.Address)
+		}
+	}
+	for _, prestate := range prestates {
+		for addr, account := range prestate {
+			slots := touch(addr)
+			if account == nil {
+				continue
+			}
+			for slot := range account.Storage {
+				slots[slot] = struct{}{}
+			}
+		}
+	}
+
+	accounts := make(map[common.Address][]common.Hash, len(touched))
+	for addr, slots := range touched {
+		keys := make([]common.Hash, 0, len(slots))
+		for slot := range slots {
+			keys = append(keys, slot)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i][:], keys[j][:]) < 0
+		})
+		accounts[addr] = keys
+	}
+	return accounts
+}
+
+// SortedAddresses returns the addresses of accounts in a stable order
+func SortedAddresses(accounts map[common.Address][]common.Hash) []common.Address {
+	addrs := make([]common.Address, 0, len(accounts))
+	for addr := range accounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	return addrs
+}
+
+// GetAccount reads the state of an account and the given storage slots at a
+// block height
+func GetAccount(ctx context.Context, rpcClient *rpc.RpcClient, addr common.Address, slots []common.Hash, number uint64) (*Account, error) {
+	num := new(big.Int).SetUint64(number)
+	balance, err := rpcClient.GetBalanceAt(ctx, addr, num)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := rpcClient.GetNonceAt(ctx, addr, num)
+	if err != nil {
+		return nil, err
+	}
+	code, err := rpcClient.GetCodeAt(ctx, addr, num)
+	if err != nil {
+		return nil, err
+	}
+	account := &Account{
+		Address:  addr,
+		Balance:  balance,
+		Nonce:    nonce,
+		CodeHash: crypto.Keccak256Hash(code),
+		Storage:  make(map[common.Hash]common.Hash, len(slots)),
+	}
+	for _, slot := range slots {
+		value, err := rpcClient.GetStorageAt(ctx, addr, slot, num)
+		if err != nil {
+			return nil, err
+		}
+		account.Storage[slot] = value
+	}
+	return account, nil
+}
+
+// CompareAccounts compares the state of an account on the legacy and the
+// migrated chain. Storage slots are compared in order.
+func CompareAccounts(legacy *Account, migrated *Account) []FieldDiff {
+	d := &differ{}
+	d.check("balance", legacy.Balance.Cmp(migrated.Balance) == 0, legacy.Balance, migrated.Balance)
+	d.check("nonce", legacy.Nonce == migrated.Nonce, legacy.Nonce, migrated.Nonce)
+	d.check("codeHash", legacy.CodeHash == migrated.CodeHash, legacy.CodeHash, migrated.CodeHash)
+
+	slots := make([]common.Hash, 0, len(legacy.Storage))
+	for slot := range legacy.Storage {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		return bytes.Compare(slots[i][:], slots[j][:]) < 0
+	})
+	for _, slot := range slots {
+		d.check(fmt.Sprintf("storage[%s]", slot), legacy.Storage[slot] == migrated.Storage[slot], legacy.Storage[slot], migrated.Storage[slot])
+	}
+	return d.diffs
+}
@@ -0,0 +1,22 @@
+package verify
+
+import (
+	"bytes"
+
+	"github.com/Boyuan-Chen/v3-migration/rpc"
+)
+
+// CompareTransactionMeta compares the rollup metadata of a migrated
+// transaction with the legacy transaction
+func CompareTransactionMeta(legacy *rpc.LegacyTransactionMeta, migrated *rpc.LegacyTransactionMeta) []FieldDiff {
+	d := &differ{}
+	d.check("l1BlockNumber", equalBig(legacy.L1BlockNumber, migrated.L1BlockNumber), legacy.L1BlockNumber, migrated.L1BlockNumber)
+	d.check("l1Timestamp", legacy.L1Timestamp == migrated.L1Timestamp, legacy.L1Timestamp, migrated.L1Timestamp)
+	d.check("l1Turing", bytes.Equal(legacy.L1Turing, migrated.L1Turing), legacy.L1Turing, migrated.L1Turing)
+	d.check("l1MessageSender", equalAddress(legacy.L1MessageSender, migrated.L1MessageSender), legacy.L1MessageSender, migrated.L1MessageSender)
+	d.check("queueOrigin", legacy.QueueOrigin == migrated.QueueOrigin, legacy.QueueOrigin, migrated.QueueOrigin)
+	d.check("index", equalUint64(legacy.Index, migrated.Index), legacy.Index, migrated.Index)
+	d.check("queueIndex", equalUint64(legacy.QueueIndex, migrated.QueueIndex), legacy.QueueIndex, migrated.QueueIndex)
+	d.check("rawTransaction", bytes.Equal(legacy.RawTransaction, migrated.RawTransaction), legacy.RawTransaction, migrated.RawTransaction)
+	return d.diffs
+}
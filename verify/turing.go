@@ -0,0 +1,22 @@
+package verify
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// CompareTuring compares the Turing payload of a migrated transaction with
+// the legacy payload. Only the first differing byte is reported, together
+// with the length if that differs too.
+func CompareTuring(legacy hexutil.Bytes, migrated hexutil.Bytes) []FieldDiff {
+	d := &differ{}
+	d.check("l1Turing.length", len(legacy) == len(migrated), len(legacy), len(migrated))
+	for i := 0; i < len(legacy) && i < len(migrated); i++ {
+		if legacy[i] != migrated[i] {
+			d.diffs = append(d.diffs, FieldDiff{Field: fmt.Sprintf("l1Turing[%d]", i), Legacy: legacy[i], Migrated: migrated[i]})
+			break
+		}
+	}
+	return d.diffs
+}